@@ -95,20 +95,41 @@ type Coil interface {
 
 type InputRegister interface {
 	Read() (uint16, error)
+	// ReadScaled reads the register in engineering units.
+	ReadScaled(s Scaling) (float64, error)
 }
 
 type InputRegisters interface {
 	Read() ([]uint16, error)
 	ReadString() (string, error)
+	// ReadStringFormat reads text stored in format.
+	ReadStringFormat(format StringFormat) (string, error)
+	// ReadAs reads and decodes the first codec.Words() registers.
+	ReadAs(codec Codec) (interface{}, error)
+	// ReadScaled reads the first registers in engineering units.
+	ReadScaled(s Scaling) (float64, error)
 }
 
 type HoldingRegister interface {
 	InputRegister
 	Write(uint16) error
+	// WriteScaled writes a value in engineering units.
+	WriteScaled(s Scaling, value float64) error
+	// Bit accesses bit n, counting from the least significant bit 0.
+	Bit(n uint) Coil
+	// Field accesses width bits starting at bit shift.
+	Field(shift, width uint) RegisterField
 }
 
 type HoldingRegisters interface {
 	InputRegisters
 	Write([]uint16) error
 	WriteString(s string) error
+	// WriteStringFormat writes text in format, from the first register.
+	WriteStringFormat(s string, format StringFormat) error
+	// WriteAs encodes v and writes it from the first register.
+	WriteAs(codec Codec, v interface{}) error
+	// WriteScaled writes a value in engineering units from the first
+	// register.
+	WriteScaled(s Scaling, value float64) error
 }
@@ -3,7 +3,6 @@ package modbus
 import (
 	"bytes"
 	"encoding/hex"
-	"fmt"
 	"time"
 )
 
@@ -58,29 +57,30 @@ func (ascii *ASCIIPackager) Verify(aduRequest []byte, aduResponse []byte) (err e
 	length := len(aduResponse)
 	// Minimum size (including address, function and LRC)
 	if length < asciiMinSize+6 {
-		err = fmt.Errorf("modbus: response length '%v' does not meet minimum '%v'", length, 9)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response length '%v' does not meet minimum '%v'", length, 9)
 		return
 	}
 	// Length excluding colon must be an even number
 	if length%2 != 1 {
-		err = fmt.Errorf("modbus: response length '%v' is not an even number", length-1)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response length '%v' is not an even number", length-1)
 		return
 	}
 	// First char must be a colon
 	str := string(aduResponse[0:len(asciiStart)])
 	if str != asciiStart {
-		err = fmt.Errorf("modbus: response frame '%v'... is not started with '%v'", str, asciiStart)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response frame '%v'... is not started with '%v'", str, asciiStart)
 		return
 	}
 	// 2 last chars must be \r\n
 	str = string(aduResponse[len(aduResponse)-len(asciiEnd):])
 	if str != asciiEnd {
-		err = fmt.Errorf("modbus: response frame ...'%v' is not ended with '%v'", str, asciiEnd)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response frame ...'%v' is not ended with '%v'", str, asciiEnd)
 		return
 	}
 	// Slave id
 	responseVal, err := readHex(aduResponse[1:])
 	if err != nil {
+		err = framingError(aduResponse, err)
 		return
 	}
 	requestVal, err := readHex(aduRequest[1:])
@@ -88,7 +88,7 @@ func (ascii *ASCIIPackager) Verify(aduRequest []byte, aduResponse []byte) (err e
 		return
 	}
 	if responseVal != requestVal {
-		err = fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", responseVal, requestVal)
+		err = frameErrorf(ErrUnitIDMismatch, aduResponse, "modbus: response slave id '%v' does not match request '%v'", responseVal, requestVal)
 		return
 	}
 	return
@@ -100,10 +100,12 @@ func (ascii *ASCIIPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error
 	// Slave address
 	address, err := readHex(adu[1:])
 	if err != nil {
+		err = framingError(adu, err)
 		return
 	}
 	// Function code
 	if pdu.FunctionCode, err = readHex(adu[3:]); err != nil {
+		err = framingError(adu, err)
 		return
 	}
 	// Data
@@ -111,11 +113,13 @@ func (ascii *ASCIIPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error
 	data := adu[5:dataEnd]
 	pdu.Data = make([]byte, hex.DecodedLen(len(data)))
 	if _, err = hex.Decode(pdu.Data, data); err != nil {
+		err = framingError(adu, err)
 		return
 	}
 	// LRC
 	lrcVal, err := readHex(adu[dataEnd:])
 	if err != nil {
+		err = framingError(adu, err)
 		return
 	}
 	// Calculate checksum
@@ -123,13 +127,13 @@ func (ascii *ASCIIPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error
 	lrc.reset()
 	lrc.pushByte(address).pushByte(pdu.FunctionCode).pushBytes(pdu.Data)
 	if lrcVal != lrc.value() {
-		err = fmt.Errorf("modbus: response lrc '%v' does not match expected '%v'", lrcVal, lrc.value())
+		err = frameErrorf(ErrChecksum, adu, "modbus: response lrc '%v' does not match expected '%v'", lrcVal, lrc.value())
 		return
 	}
 	return
 }
 
-func (ascii *ASCIIPackager) transceive(transporter Transporter, logger Logger, aduRequest []byte, timeout time.Duration) (aduResponse []byte, err error) {
+func (ascii *ASCIIPackager) transceive(transporter Transporter, aduRequest []byte, timeout time.Duration, enron enronRanges) (aduResponse []byte, err error) {
 	// Make sure port is connected
 	if err = transporter.Connect(); err != nil {
 		return
@@ -143,7 +147,6 @@ func (ascii *ASCIIPackager) transceive(transporter Transporter, logger Logger, a
 	}
 
 	// Send the request
-	log(logger, "modbus: sending %q\n", aduRequest)
 	if _, err = transporter.Write(aduRequest); err != nil {
 		return
 	}
@@ -168,10 +171,16 @@ func (ascii *ASCIIPackager) transceive(transporter Transporter, logger Logger, a
 		}
 	}
 	aduResponse = data[:length]
-	log(logger, "modbus: received %q\n", aduResponse)
 	return
 }
 
+// framingError reports a response that is not valid hexadecimal.
+func framingError(adu []byte, err error) error {
+	e := wrapError(ErrFraming, err)
+	e.ADU = adu
+	return e
+}
+
 // writeHex encodes byte to string in hexadecimal, e.g. 0xA5 => "A5"
 // (encoding/hex only supports lowercase string).
 func writeHex(buf *bytes.Buffer, value []byte) (err error) {
@@ -0,0 +1,281 @@
+package modbus
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Future is the result of a request sent by Async, available once Done is
+// closed.
+type Future struct {
+	done      chan struct{}
+	mu        sync.Mutex
+	callbacks []func(f *Future)
+
+	bits      []bool
+	registers []uint16
+	err       error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Done is closed when the request completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err waits for the request and returns its error.
+func (f *Future) Err() error {
+	<-f.done
+	return f.err
+}
+
+// Bits waits for a request reading coils or discrete inputs and returns
+// their status.
+func (f *Future) Bits() ([]bool, error) {
+	<-f.done
+	return f.bits, f.err
+}
+
+// Registers waits for a request reading registers and returns their
+// values.
+func (f *Future) Registers() ([]uint16, error) {
+	<-f.done
+	return f.registers, f.err
+}
+
+// Then calls fn with f when the request completed, on the worker of the
+// Async that sent it, or right away if it already did. fn must not block.
+func (f *Future) Then(fn func(f *Future)) {
+	f.mu.Lock()
+	select {
+	case <-f.done:
+		f.mu.Unlock()
+		fn(f)
+		return
+	default:
+	}
+	f.callbacks = append(f.callbacks, fn)
+	f.mu.Unlock()
+}
+
+// complete sets the result of f and runs its callbacks.
+func (f *Future) complete(bits []bool, registers []uint16, err error) {
+	f.mu.Lock()
+	f.bits, f.registers, f.err = bits, registers, err
+	close(f.done)
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(f)
+	}
+}
+
+// Async sends requests without blocking the caller. A single worker
+// goroutine serves all of them on the transport of the client, keeping up
+// to the depth of WithPipelining in flight on Modbus TCP, matched by
+// transaction id, and one at a time otherwise:
+//
+//	async := modbus.NewAsync(client)
+//	defer async.Close()
+//	f := async.ReadHoldingRegisters(100, 4)
+//	g := async.SetSlaveID(2).ReadHoldingRegisters(100, 4)
+//	values, err := f.Registers()
+//
+// Requests keep their order per slave. Change the slave with SetSlaveID of
+// Async, not of the wrapped ClientHandler.
+type Async struct {
+	client *ClientHandler
+
+	mu     sync.Mutex
+	slave  byte
+	queue  []*asyncOp
+	closed bool
+	wake   chan struct{}
+	exited chan struct{}
+}
+
+// asyncOp is a request queued for the worker.
+type asyncOp struct {
+	slaveID byte
+	request *request
+	future  *Future
+}
+
+// NewAsync starts the worker sending the requests of client.
+func NewAsync(client *ClientHandler) *Async {
+	client.mu.Lock()
+	slave := client.SlaveID
+	client.mu.Unlock()
+	a := &Async{
+		client: client,
+		slave:  slave,
+		wake:   make(chan struct{}, 1),
+		exited: make(chan struct{}),
+	}
+	go a.work()
+	return a
+}
+
+// SetSlaveID sends later requests to slaveID.
+func (a *Async) SetSlaveID(slaveID byte) *Async {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.slave = slaveID
+	return a
+}
+
+// Close stops the worker after the requests in flight. Requests still
+// queued fail. The client stays open.
+func (a *Async) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	queue := a.queue
+	a.queue = nil
+	close(a.wake)
+	a.mu.Unlock()
+	for _, op := range queue {
+		op.future.complete(nil, nil, fmt.Errorf("modbus: async client closed"))
+	}
+	<-a.exited
+	return nil
+}
+
+// send queues r and returns its future.
+func (a *Async) send(r *request) *Future {
+	f := newFuture()
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		f.complete(nil, nil, fmt.Errorf("modbus: async client closed"))
+		return f
+	}
+	a.queue = append(a.queue, &asyncOp{slaveID: a.slave, request: r, future: f})
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	a.mu.Unlock()
+	return f
+}
+
+// work serves the queue until Close.
+func (a *Async) work() {
+	defer close(a.exited)
+	for range a.wake {
+		for {
+			batch := a.next()
+			if batch == nil {
+				break
+			}
+			a.run(batch)
+		}
+	}
+}
+
+// next takes the requests to the slave of the oldest request.
+func (a *Async) next() (batch []*asyncOp) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.queue) == 0 {
+		return nil
+	}
+	slaveID := a.queue[0].slaveID
+	rest := a.queue[:0]
+	for _, op := range a.queue {
+		if op.slaveID == slaveID {
+			batch = append(batch, op)
+		} else {
+			rest = append(rest, op)
+		}
+	}
+	for i := len(rest); i < len(a.queue); i++ {
+		a.queue[i] = nil
+	}
+	a.queue = rest
+	return
+}
+
+// run sends a batch of requests to one slave and completes their futures.
+func (a *Async) run(batch []*asyncOp) {
+	c := a.client
+	slaveID := batch[0].slaveID
+	if depth, _ := c.pipelineDepth(); depth > 1 && len(batch) > 1 {
+		requests := make([]*request, len(batch))
+		for i, op := range batch {
+			requests[i] = op.request
+		}
+		results, _ := c.roundTripBatch(&slaveID, requests, depth)
+		for i, res := range results {
+			batch[i].future.complete(res.bits, res.registers, res.err)
+		}
+		return
+	}
+	for _, op := range batch {
+		res, _ := c.roundTrip(&slaveID, op.request)
+		op.future.complete(res.bits, res.registers, res.err)
+	}
+}
+
+// ReadDiscreteInputs reads from 1 to 2000 discrete inputs, see Future.Bits.
+func (a *Async) ReadDiscreteInputs(address, quantity uint16) *Future {
+	return a.send(readBitsRequest(FuncCodeReadDiscreteInputs, address, quantity))
+}
+
+// ReadCoils reads from 1 to 2000 coils, see Future.Bits.
+func (a *Async) ReadCoils(address, quantity uint16) *Future {
+	return a.send(readBitsRequest(FuncCodeReadCoils, address, quantity))
+}
+
+// WriteSingleCoil sets a coil ON or OFF.
+func (a *Async) WriteSingleCoil(address uint16, coil bool) *Future {
+	return a.send(writeSingleCoilRequest(address, coil))
+}
+
+// WriteMultipleCoils sets from 1 to 1968 coils.
+func (a *Async) WriteMultipleCoils(address uint16, coils []bool) *Future {
+	return a.send(writeMultipleCoilsRequest(address, coils))
+}
+
+// ReadHoldingRegisters reads holding registers, see Future.Registers.
+func (a *Async) ReadHoldingRegisters(address, quantity uint16) *Future {
+	return a.send(readRegistersRequest(a.client.enron, FuncCodeReadHoldingRegisters, address, quantity))
+}
+
+// ReadInputRegisters reads input registers, see Future.Registers.
+func (a *Async) ReadInputRegisters(address, quantity uint16) *Future {
+	return a.send(readRegistersRequest(a.client.enron, FuncCodeReadInputRegisters, address, quantity))
+}
+
+// WriteSingleRegister writes a holding register.
+func (a *Async) WriteSingleRegister(address, value uint16) *Future {
+	return a.send(writeSingleRegisterRequest(address, value))
+}
+
+// WriteMultipleRegisters writes from 1 to 123 holding registers.
+func (a *Async) WriteMultipleRegisters(address uint16, values []uint16) *Future {
+	return a.send(writeMultipleRegistersRequest(address, values))
+}
+
+// ReadWriteMultipleRegisters writes and then reads holding registers in one
+// request, see Future.Registers.
+func (a *Async) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) *Future {
+	return a.send(readWriteMultipleRegistersRequest(readAddress, readQuantity, writeAddress, writeQuantity, value))
+}
+
+// MaskWriteRegister changes bits of a holding register.
+func (a *Async) MaskWriteRegister(address, andMask, orMask uint16) *Future {
+	return a.send(maskWriteRegisterRequest(address, andMask, orMask))
+}
+
+// ReadFIFOQueue reads the registers of a FIFO queue, see Future.Registers.
+func (a *Async) ReadFIFOQueue(address uint16) *Future {
+	return a.send(readFIFOQueueRequest(address))
+}
@@ -0,0 +1,203 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited write.
+const (
+	AuditSuccess   = "success"
+	AuditException = "exception"
+	AuditError     = "error"
+)
+
+// AuditRecord describes a write request, function code 5, 6, 15, 16, 22
+// or 23.
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Actor        string    `json:"actor,omitempty"`
+	Transport    string    `json:"transport"`
+	Endpoint     string    `json:"endpoint"`
+	SlaveID      byte      `json:"slave_id"`
+	FunctionCode byte      `json:"function_code"`
+	Address      uint16    `json:"address"`
+	Quantity     uint16    `json:"quantity"`
+	// Bits or Registers written. For function code 22 Registers holds the
+	// AND and OR masks.
+	Bits      []bool   `json:"bits,omitempty"`
+	Registers []uint16 `json:"registers,omitempty"`
+	// PreviousBits or PreviousRegisters are the values before the write,
+	// if read, see Audit.
+	PreviousBits      []bool   `json:"previous_bits,omitempty"`
+	PreviousRegisters []uint16 `json:"previous_registers,omitempty"`
+	// Outcome is AuditSuccess, AuditException or AuditError, Err the
+	// message of the failure. Writes acknowledged with a wrong echo or
+	// reading back different, see WithWriteVerify, are errors.
+	Outcome string `json:"outcome"`
+	Err     string `json:"error,omitempty"`
+}
+
+// AuditSink receives the records of audited writes. Audit is called with
+// the client locked, so it must not use the client.
+type AuditSink interface {
+	Audit(r *AuditRecord) error
+}
+
+// Audit configures the write journal of a client, see WithAudit.
+type Audit struct {
+	Sink AuditSink
+	// Actor names who writes, such as a user or script, see also
+	// SetAuditActor.
+	Actor string
+	// ReadPrevious reads the coils or registers before each write to
+	// record their previous values. Pipelined writes are recorded without.
+	ReadPrevious bool
+}
+
+// SetAuditActor changes the actor of the records of later writes.
+func (c *ClientHandler) SetAuditActor(actor string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.audit != nil {
+		c.audit.Actor = actor
+	}
+}
+
+// auditStart returns the record of request if it is an audited write,
+// reading the previous values if configured and previous. The caller must
+// hold c.mu.
+func (c *ClientHandler) auditStart(request *ProtocolDataUnit, previous bool) *AuditRecord {
+	if c.audit == nil {
+		return nil
+	}
+	r := auditRecord(request)
+	if r == nil {
+		return nil
+	}
+	labels := c.metricLabels(request.FunctionCode)
+	r.Time = time.Now()
+	r.Actor = c.audit.Actor
+	r.Transport = labels.Transport
+	r.Endpoint = labels.Endpoint
+	r.SlaveID = c.SlaveID
+	if !previous || !c.audit.ReadPrevious {
+		return r
+	}
+	read := readRegistersRequest(c.enron, FuncCodeReadHoldingRegisters, r.Address, r.Quantity)
+	if r.Bits != nil {
+		read = readBitsRequest(FuncCodeReadCoils, r.Address, r.Quantity)
+	}
+	if read.err != nil {
+		return r
+	}
+	if res := c.send(read); res.err == nil {
+		r.PreviousBits, r.PreviousRegisters = res.bits, res.registers
+	}
+	return r
+}
+
+// auditEnd records the outcome of a write. The caller must hold c.mu.
+func (c *ClientHandler) auditEnd(r *AuditRecord, err error) {
+	if r == nil {
+		return
+	}
+	r.Outcome = AuditSuccess
+	if err != nil {
+		r.Outcome, r.Err = AuditError, err.Error()
+		if _, ok := err.(*ModbusError); ok {
+			r.Outcome = AuditException
+		}
+	}
+	if err = c.audit.Sink.Audit(r); err != nil {
+		c.log(LogLevelError, LogRecord{FunctionCode: r.FunctionCode, Err: err, Message: "audit record failed"}, nil)
+	}
+}
+
+// auditRecord returns the record of a write request, nil for other
+// requests.
+func auditRecord(request *ProtocolDataUnit) *AuditRecord {
+	data := request.Data
+	if len(data) < 4 {
+		return nil
+	}
+	r := &AuditRecord{
+		FunctionCode: request.FunctionCode,
+		Address:      binary.BigEndian.Uint16(data),
+		Quantity:     1,
+	}
+	switch request.FunctionCode {
+	case FuncCodeWriteSingleCoil:
+		r.Bits = []bool{binary.BigEndian.Uint16(data[2:]) == 0xFF00}
+	case FuncCodeWriteSingleRegister:
+		r.Registers = []uint16{binary.BigEndian.Uint16(data[2:])}
+	case FuncCodeWriteMultipleCoils:
+		r.Quantity = binary.BigEndian.Uint16(data[2:])
+		r.Bits = make([]bool, r.Quantity)
+		for i := range r.Bits {
+			if j := 5 + i/8; j < len(data) {
+				r.Bits[i] = data[j]&(1<<uint(i%8)) != 0
+			}
+		}
+	case FuncCodeWriteMultipleRegisters:
+		r.Quantity = binary.BigEndian.Uint16(data[2:])
+		r.Registers = bytesToWordArray(data[5:])
+	case FuncCodeMaskWriteRegister:
+		r.Registers = bytesToWordArray(data[2:])
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(data) < 9 {
+			return nil
+		}
+		r.Address = binary.BigEndian.Uint16(data[4:])
+		r.Quantity = binary.BigEndian.Uint16(data[6:])
+		r.Registers = bytesToWordArray(data[9:])
+	default:
+		return nil
+	}
+	return r
+}
+
+// JSONLinesSink writes audit records as lines of JSON.
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesSink writes records to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// OpenJSONLinesSink appends records to the file at path, creating it
+// readable by the owner only.
+func OpenJSONLinesSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+func (s *JSONLinesSink) Audit(r *AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file opened by OpenJSONLinesSink.
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,106 @@
+package modbus
+
+import (
+	"errors"
+)
+
+// RegisterField is a group of adjacent bits within a holding register.
+type RegisterField interface {
+	// Read returns the field value, shifted down to bit 0.
+	Read() (uint16, error)
+	// Write sets the field to value, leaving the other bits unchanged.
+	Write(value uint16) error
+}
+
+// registerBits is a field or single bit of a holding register. Writes use
+// Mask Write Register, or a locked read-modify-write on devices answering
+// it with an illegal function exception.
+type registerBits struct {
+	register *rwRegister
+	shift    uint
+	width    uint
+}
+
+func (io *rwRegister) Bit(n uint) Coil {
+	return &registerBits{register: io, shift: n, width: 1}
+}
+
+func (io *rwRegister) Field(shift, width uint) RegisterField {
+	return &registerBits{register: io, shift: shift, width: width}
+}
+
+func (f *registerBits) mask() (mask uint16, err error) {
+	if f.width < 1 || f.shift+f.width > 16 {
+		err = errorf(ErrInvalidQuantity, "modbus: field of %v bits at bit %v exceeds the register", f.width, f.shift)
+		return
+	}
+	return uint16(1<<f.width-1) << f.shift, nil
+}
+
+func (f *registerBits) Read() (value uint16, err error) {
+	mask, err := f.mask()
+	if err != nil {
+		return
+	}
+	current, err := f.register.Read()
+	if err != nil {
+		return
+	}
+	return (current & mask) >> f.shift, nil
+}
+
+func (f *registerBits) Write(value uint16) (err error) {
+	mask, err := f.mask()
+	if err != nil {
+		return
+	}
+	if value > mask>>f.shift {
+		return errorf(ErrInvalidQuantity, "modbus: value '%v' does not fit in %v bits", value, f.width)
+	}
+	f.register.lock.Lock()
+	defer f.register.lock.Unlock()
+	return f.register.modify(mask, value<<f.shift)
+}
+
+func (f *registerBits) Test() (bool, error) {
+	value, err := f.Read()
+	return value != 0, err
+}
+
+func (f *registerBits) Set() error {
+	return f.Write(1)
+}
+
+func (f *registerBits) Clear() error {
+	return f.Write(0)
+}
+
+func (f *registerBits) Toggle() (err error) {
+	mask, err := f.mask()
+	if err != nil {
+		return
+	}
+	f.register.lock.Lock()
+	defer f.register.lock.Unlock()
+	current, err := f.register.Read()
+	if err != nil {
+		return
+	}
+	return f.register.modify(mask, ^current)
+}
+
+// modify sets the bits of mask to those of value. The caller must hold
+// io.lock so that the read-modify-write fallback is not interleaved with
+// other changes of bits.
+func (io *rwRegister) modify(mask, value uint16) (err error) {
+	err = io.master.MaskWriteRegister(io.address, ^mask, value&mask)
+	var e *ModbusError
+	if !errors.As(err, &e) || e.ExceptionCode != ExceptionCodeIllegalFunction {
+		return
+	}
+	current, err := io.Read()
+	if err != nil {
+		return
+	}
+	return io.Write(current&^mask | value&mask)
+}
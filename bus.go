@@ -0,0 +1,70 @@
+package modbus
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bus shares one serial line, such as /dev/ttyUSB0, between the clients of
+// several slaves in one process:
+//
+//	bus, err := modbus.NewBus(&modbus.RTUPackager{}, modbus.NewSerialTransport("/dev/ttyUSB0", 19200, 8, "E", 1, time.Second),
+//		modbus.WithSerialTiming(modbus.SerialTiming{Gap: 5 * time.Millisecond}))
+//	meter, err := bus.Client(1, 0)
+//	relay, err := bus.Client(7, 10)
+//
+// Requests of all clients are served one at a time, the highest priority
+// first, see WithPriority. Clients share the options of the bus, the quiet
+// time of the line and the queue statistics.
+type Bus struct {
+	packager    Packager
+	transporter Transporter
+	opts        []Option
+
+	queue requestQueue
+	// quietUntil is shared by the clients, guarded by the turn on the bus.
+	quietUntil time.Time
+}
+
+// NewBus creates a bus on transporter. opts apply to every client of the
+// bus.
+func NewBus(packager Packager, transporter Transporter, opts ...Option) (*Bus, error) {
+	if packager == nil || transporter == nil {
+		return nil, fmt.Errorf("modbus: packager and transporter must not be nil")
+	}
+	if _, ok := packager.(*TCPPackager); ok {
+		return nil, fmt.Errorf("modbus: a bus requires a serial packager")
+	}
+	b := &Bus{packager: packager, transporter: transporter, opts: opts}
+	// Check the options once
+	if _, err := b.Client(0, 0); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Client returns a client of slaveID on the bus, whose requests are served
+// before those of clients of lower priority. opts add to those of the bus,
+// such as WithMaxWait. Closing the client leaves the line open, see Close.
+func (b *Bus) Client(slaveID byte, priority int, opts ...Option) (*ClientHandler, error) {
+	opts = append(append(append([]Option(nil), b.opts...), opts...), WithUnitID(slaveID), WithPriority(priority))
+	c, err := NewClientHandler(b.packager, b.transporter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.bus = b
+	c.queue = &b.queue
+	return c, nil
+}
+
+// Stats returns the statistics of the request queue of b.
+func (b *Bus) Stats() QueueStats {
+	return b.queue.snapshot()
+}
+
+// Close closes the line after the request in progress.
+func (b *Bus) Close() error {
+	b.queue.acquire(0, time.Time{})
+	defer b.queue.release()
+	return b.transporter.Close()
+}
@@ -0,0 +1,307 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a Client that serves reads of coils, discrete inputs, holding
+// and input registers from memory for a time to live, e.g. when several
+// services poll the same meters over a slow serial line:
+//
+//	cache := modbus.NewCache(client, time.Second)
+//	values, err := cache.ReadHoldingRegisters(100, 4)
+//
+// Concurrent reads of the same range of a slave share one request. Writes
+// through the cache drop the cached ranges they overlap; writes made on
+// the wrapped ClientHandler directly, or by other masters, show after the
+// time to live. The object accessors read through the cache too, except
+// that Coil and HoldingRegister read from the device, as their toggles and
+// bit changes must start from current values. Change the slave with
+// SetSlaveID of the cache, not of the wrapped ClientHandler.
+type Cache struct {
+	client *ClientHandler
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// slave is the slave id reads go to, known without waiting for the
+	// request in progress of client.
+	slave   byte
+	entries map[cacheKey]*cacheEntry
+	calls   map[cacheKey]*cacheCall
+	stats   CacheStats
+	// swept is the last time expired entries were dropped.
+	swept time.Time
+}
+
+// CacheStats are statistics of a cache.
+type CacheStats struct {
+	// Hits is the number of reads served from memory, Shared the number
+	// of reads that shared the request of another and Misses the number
+	// of requests sent.
+	Hits   uint64
+	Shared uint64
+	Misses uint64
+	// Entries is the number of results held.
+	Entries int
+}
+
+// cacheKey identifies a read.
+type cacheKey struct {
+	slaveID  byte
+	table    Table
+	address  uint16
+	quantity uint16
+}
+
+// overlaps reports whether k reads any of quantity items of table of
+// slaveID at address.
+func (k cacheKey) overlaps(slaveID byte, table Table, address, quantity uint16) bool {
+	return k.slaveID == slaveID && k.table == table &&
+		int(k.address) < int(address)+int(quantity) && int(address) < int(k.address)+int(k.quantity)
+}
+
+// cacheEntry is the result of a read.
+type cacheEntry struct {
+	bits      []bool
+	registers []uint16
+	expires   time.Time
+}
+
+// cacheCall is a read in progress, whose result is shared by the reads
+// arriving meanwhile.
+type cacheCall struct {
+	done chan struct{}
+	cacheEntry
+	err error
+	// stale is set by an overlapping write, the result is not cached.
+	stale bool
+}
+
+// NewCache wraps client with a cache keeping results for ttl. A zero ttl
+// only shares concurrent reads.
+func NewCache(client *ClientHandler, ttl time.Duration) *Cache {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return &Cache{
+		client:  client,
+		ttl:     ttl,
+		slave:   client.SlaveID,
+		entries: make(map[cacheKey]*cacheEntry),
+		calls:   make(map[cacheKey]*cacheCall),
+	}
+}
+
+// Stats returns the statistics of c.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Invalidate drops all cached results.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]*cacheEntry)
+	for _, call := range c.calls {
+		call.stale = true
+	}
+}
+
+// read returns quantity bits or registers of table at address, from
+// memory if possible.
+func (c *Cache) read(table Table, address, quantity uint16) (*cacheEntry, error) {
+	c.mu.Lock()
+	key := cacheKey{slaveID: c.slave, table: table, address: address, quantity: quantity}
+	if e := c.entries[key]; e != nil {
+		if time.Now().Before(e.expires) {
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e, nil
+		}
+		delete(c.entries, key)
+	}
+	if call := c.calls[key]; call != nil {
+		c.stats.Shared++
+		c.mu.Unlock()
+		<-call.done
+		return &call.cacheEntry, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	call.bits, call.registers, call.err = c.client.readTable(key.slaveID, table, address, quantity)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err == nil && !call.stale && c.ttl > 0 {
+		now := time.Now()
+		c.sweep(now)
+		call.expires = now.Add(c.ttl)
+		c.entries[key] = &call.cacheEntry
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return &call.cacheEntry, call.err
+}
+
+// sweep drops the expired entries, at most once per time to live, so that
+// ranges read once do not stay in memory. The caller must hold c.mu.
+func (c *Cache) sweep(now time.Time) {
+	if now.Sub(c.swept) < c.ttl {
+		return
+	}
+	c.swept = now
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// readBits returns a copy of the bits read, as callers may change them.
+func (c *Cache) readBits(table Table, address, quantity uint16) ([]bool, error) {
+	e, err := c.read(table, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return append([]bool(nil), e.bits...), nil
+}
+
+// readRegisters returns a copy of the registers read.
+func (c *Cache) readRegisters(table Table, address, quantity uint16) ([]uint16, error) {
+	e, err := c.read(table, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return append([]uint16(nil), e.registers...), nil
+}
+
+// invalidate drops the cached results overlapping a write of quantity items
+// of table at address, and keeps the reads in progress from being cached.
+func (c *Cache) invalidate(table Table, address, quantity uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.overlaps(c.slave, table, address, quantity) {
+			delete(c.entries, key)
+		}
+	}
+	for key, call := range c.calls {
+		if key.overlaps(c.slave, table, address, quantity) {
+			call.stale = true
+		}
+	}
+}
+
+func (c *Cache) SetLogger(logger Logger) {
+	c.client.SetLogger(logger)
+}
+
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
+
+func (c *Cache) SetSlaveID(slaveID byte) Client {
+	c.mu.Lock()
+	c.slave = slaveID
+	c.mu.Unlock()
+	c.client.SetSlaveID(slaveID)
+	return c
+}
+
+func (c *Cache) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	return c.readBits(TableDiscreteInputs, address, quantity)
+}
+
+func (c *Cache) ReadCoils(address, quantity uint16) ([]bool, error) {
+	return c.readBits(TableCoils, address, quantity)
+}
+
+func (c *Cache) WriteSingleCoil(address uint16, coil bool) error {
+	defer c.invalidate(TableCoils, address, 1)
+	return c.client.WriteSingleCoil(address, coil)
+}
+
+func (c *Cache) WriteMultipleCoils(address uint16, coils []bool) error {
+	defer c.invalidate(TableCoils, address, uint16(len(coils)))
+	return c.client.WriteMultipleCoils(address, coils)
+}
+
+func (c *Cache) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(TableHoldingRegisters, address, quantity)
+}
+
+func (c *Cache) ReadInputRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(TableInputRegisters, address, quantity)
+}
+
+func (c *Cache) WriteSingleRegister(address, value uint16) error {
+	defer c.invalidate(TableHoldingRegisters, address, 1)
+	return c.client.WriteSingleRegister(address, value)
+}
+
+func (c *Cache) WriteMultipleRegisters(address uint16, values []uint16) error {
+	defer c.invalidate(TableHoldingRegisters, address, uint16(len(values)))
+	return c.client.WriteMultipleRegisters(address, values)
+}
+
+// ReadWriteMultipleRegisters is not cached, as it writes.
+func (c *Cache) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]uint16, error) {
+	defer c.invalidate(TableHoldingRegisters, writeAddress, writeQuantity)
+	return c.client.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+}
+
+func (c *Cache) MaskWriteRegister(address, andMask, orMask uint16) error {
+	defer c.invalidate(TableHoldingRegisters, address, 1)
+	return c.client.MaskWriteRegister(address, andMask, orMask)
+}
+
+// ReadFIFOQueue is not cached, as reading takes the values from the queue.
+func (c *Cache) ReadFIFOQueue(address uint16) ([]uint16, error) {
+	return c.client.ReadFIFOQueue(address)
+}
+
+func (c *Cache) DiscreteInput(address uint16) DiscreteInput {
+	return &roBit{master: c, address: address}
+}
+
+func (c *Cache) Coil(address uint16) Coil {
+	return &rwBit{master: cacheBypass{c}, address: address, lock: &c.client.rmw}
+}
+
+func (c *Cache) InputRegister(address uint16) InputRegister {
+	return &roRegister{master: c, address: address}
+}
+
+func (c *Cache) InputRegisters(address, count uint16) InputRegisters {
+	return &roRegisters{master: c, address: address, count: count}
+}
+
+func (c *Cache) HoldingRegister(address uint16) HoldingRegister {
+	return &rwRegister{master: cacheBypass{c}, address: address, lock: &c.client.rmw}
+}
+
+// cacheBypass is the master of the read-modify-write accessors of a cache:
+// it reads coils and holding registers from the device and writes through
+// the cache, dropping the cached values written.
+type cacheBypass struct {
+	*Cache
+}
+
+func (b cacheBypass) ReadCoils(address, quantity uint16) ([]bool, error) {
+	return b.client.ReadCoils(address, quantity)
+}
+
+func (b cacheBypass) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	return b.client.ReadHoldingRegisters(address, quantity)
+}
+
+func (c *Cache) HoldingRegisters(address, count uint16) HoldingRegisters {
+	return &rwRegisters{master: c, address: address, count: count}
+}
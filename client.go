@@ -2,6 +2,7 @@ package modbus
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
@@ -19,25 +20,152 @@ type ClientHandler struct {
 	Timeout     time.Duration
 	Logger      Logger
 	mu          sync.Mutex
+
+	// Settings only available through options, see NewClientHandler.
+	retries        int
+	retryBackoff   time.Duration
+	reconnect      bool
+	timing         SerialTiming
+	slaveTiming    map[byte]SerialTiming
+	connectTimeout time.Duration
+	handler        LogHandler
+	logFrames      bool
+	metrics        Metrics
+	pipeline       int
+	enron          enronRanges
+	zeroBased      bool
+	verify         *writeVerify
+	audit          *Audit
+	bus            *Bus
+	queue          *requestQueue
+	priority       int
+	maxWait        time.Duration
+	pool           *TCPPool
+
+	// End of the quiet time after the last exchange, see SerialTiming.
+	quietTime time.Time
+	// Held across read-modify-write sequences of bits.
+	rmw sync.Mutex
+}
+
+// NewClientHandler creates a client handler for any combination of packager
+// and transporter, configured by opts. It reports an error when an option
+// value is invalid or when options do not apply to the given combination,
+// e.g. a connect timeout on a serial port.
+func NewClientHandler(packager Packager, transporter Transporter, opts ...Option) (c *ClientHandler, err error) {
+	if packager == nil || transporter == nil {
+		err = fmt.Errorf("modbus: packager and transporter must not be nil")
+		return
+	}
+	c = &ClientHandler{
+		Packager:    packager,
+		Transporter: transporter,
+	}
+	for _, opt := range opts {
+		if err = opt(c); err != nil {
+			c = nil
+			return
+		}
+	}
+	if err = c.validate(); err != nil {
+		c = nil
+	}
+	return
 }
 
-func (c *ClientHandler) Connect() error {
+// validate checks that the option settings apply to the packager and
+// transporter, and pushes transport settings down to the transporter.
+func (c *ClientHandler) validate() error {
+	if _, ok := c.Packager.(*TCPPackager); ok && (c.timing != SerialTiming{} || len(c.slaveTiming) > 0) {
+		return fmt.Errorf("modbus: serial bus delays do not apply to Modbus TCP")
+	}
+	if _, ok := c.Packager.(*TCPPackager); !ok && c.pipeline > 1 {
+		return fmt.Errorf("modbus: pipelining requires Modbus TCP")
+	}
+	if len(c.enron) > 0 {
+		_, rtu := c.Packager.(*RTUPackager)
+		if _, tcp := c.Packager.(*TCPPackager); !rtu && !tcp {
+			return fmt.Errorf("modbus: Enron registers require Modbus TCP or RTU")
+		}
+	}
+	if c.connectTimeout > 0 {
+		tcp, ok := c.Transporter.(*tcpAddrCategoryPort)
+		if !ok {
+			return fmt.Errorf("modbus: connect timeout requires a TCP address transport, got %T", c.Transporter)
+		}
+		tcp.connectTimeout = c.connectTimeout
+	}
+	if c.pool != nil {
+		tcp, ok := c.Transporter.(*tcpAddrCategoryPort)
+		if !ok {
+			return fmt.Errorf("modbus: a connection pool requires a TCP address transport, got %T", c.Transporter)
+		}
+		c.Transporter = &pooledTransport{pool: c.pool, address: tcp.address, connectTimeout: tcp.connectTimeout}
+	}
+	if _, ok := c.Transporter.(*tcpConnCategoryPort); ok && c.reconnect {
+		return fmt.Errorf("modbus: reconnect is not supported on an existing TCP connection")
+	}
+	return nil
+}
+
+func (c *ClientHandler) Connect() (err error) {
+	release, err := c.turn(0)
+	if err != nil {
+		return err
+	}
+	defer release()
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer func() {
+		c.done(err)
+	}()
 	return c.Transporter.Connect()
 }
 
 func (c *ClientHandler) SetLogger(l Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Logger = l
 }
 
+// SetLogHandler assigns the handler structured log records are sent to.
+// It takes precedence over the logger assigned with SetLogger. Frames are
+// only included when enabled with WithFrameLogging.
+func (c *ClientHandler) SetLogHandler(h LogHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = h
+}
+
+// SetFrameLogging includes or omits the raw frames in the records sent to
+// the log handler.
+func (c *ClientHandler) SetFrameLogging(enable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logFrames = enable
+}
+
+// SetMetrics assigns the receiver of request measurements.
+func (c *ClientHandler) SetMetrics(m Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+}
+
+// Close closes the transporter, except for clients of a Bus, which leave
+// the line open for the other clients.
 func (c *ClientHandler) Close() error {
+	if c.bus != nil {
+		return nil
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.Transporter.Close()
 }
 
 func (c *ClientHandler) SetSlaveID(slaveID byte) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.SlaveID = slaveID
 	return c
 }
@@ -51,33 +179,8 @@ func (c *ClientHandler) SetSlaveID(slaveID byte) Client {
 //  Byte count            : 1 byte
 //  Input status          : N* bytes (=N or N+1)
 func (c *ClientHandler) ReadDiscreteInputs(address, quantity uint16) (inputs []bool, err error) {
-	if quantity < 1 || quantity > 2000 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 2000)
-		return
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadDiscreteInputs,
-		Data:         dataBlock(address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	byteCount := int(response.Data[0])
-	length := len(response.Data) - 1
-	if byteCount != length {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, byteCount)
-		return
-	}
-
-	result := response.Data[1:]
-	inputs = make([]bool, byteCount*8)
-	for i := 0; i < byteCount; i++ {
-		for j := 0; j < 8; j++ {
-			inputs[i*8+j] = (result[i] & (1 << uint(j))) != 0
-		}
-	}
-	return inputs[0:quantity], nil
+	res := c.call(nil, readBitsRequest(FuncCodeReadDiscreteInputs, address, quantity))
+	return res.bits, res.err
 }
 
 // Request:
@@ -89,33 +192,8 @@ func (c *ClientHandler) ReadDiscreteInputs(address, quantity uint16) (inputs []b
 //  Byte count            : 1 byte
 //  Coil status           : N* bytes (=N or N+1)
 func (c *ClientHandler) ReadCoils(address, quantity uint16) (coils []bool, err error) {
-	if quantity < 1 || quantity > 2000 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 2000)
-		return
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadCoils,
-		Data:         dataBlock(address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	byteCount := int(response.Data[0])
-	length := len(response.Data) - 1
-	if byteCount != length {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, byteCount)
-		return
-	}
-
-	coilStates := response.Data[1:]
-	coils = make([]bool, byteCount*8)
-	for i := 0; i < byteCount; i++ {
-		for j := 0; j < 8; j++ {
-			coils[i*8+j] = (coilStates[i] & (1 << uint(j))) != 0
-		}
-	}
-	return coils[0:quantity], nil
+	res := c.call(nil, readBitsRequest(FuncCodeReadCoils, address, quantity))
+	return res.bits, res.err
 }
 
 // Request:
@@ -127,38 +205,7 @@ func (c *ClientHandler) ReadCoils(address, quantity uint16) (coils []bool, err e
 //  Output address        : 2 bytes
 //  Output value          : 2 bytes
 func (c *ClientHandler) WriteSingleCoil(address uint16, coil bool) (err error) {
-	// The requested ON/OFF state can only be 0xFF00 and 0x0000
-	var value uint16
-	if coil {
-		value = 0xFF00
-	} else {
-		value = 0x0000
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeWriteSingleCoil,
-		Data:         dataBlock(address, value),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	// Fixed response length
-	if len(response.Data) != 4 {
-		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
-		return
-	}
-	respValue := binary.BigEndian.Uint16(response.Data)
-	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
-		return
-	}
-	results := response.Data[2:]
-	respValue = binary.BigEndian.Uint16(results)
-	if value != respValue {
-		err = fmt.Errorf("modbus: response value '%v' does not match request '%v'", respValue, value)
-		return
-	}
-	return
+	return c.call(nil, writeSingleCoilRequest(address, coil)).err
 }
 
 // Request:
@@ -172,46 +219,7 @@ func (c *ClientHandler) WriteSingleCoil(address uint16, coil bool) (err error) {
 //  Starting address      : 2 bytes
 //  Quantity of outputs   : 2 bytes
 func (c *ClientHandler) WriteMultipleCoils(address uint16, coils []bool) (err error) {
-	count := len(coils)
-	if count < 1 || count > 1968 {
-		err = fmt.Errorf("modbus: quantity '%v' (len(coils)) must be between '%v' and '%v'", count, 1, 1968)
-		return
-	}
-	quantity := uint16(count)
-
-	byteCount := uint((quantity + 7) / 8)
-	value := make([]byte, byteCount)
-	for i, v := range coils {
-		if v {
-			value[i>>3] |= 1 << (uint(i) & 7)
-		}
-	}
-
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeWriteMultipleCoils,
-		Data:         dataBlockSuffix(value, address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	// Fixed response length
-	if len(response.Data) != 4 {
-		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
-		return
-	}
-	respValue := binary.BigEndian.Uint16(response.Data)
-	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
-		return
-	}
-	results := response.Data[2:]
-	respValue = binary.BigEndian.Uint16(results)
-	if quantity != respValue {
-		err = fmt.Errorf("modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
-		return
-	}
-	return
+	return c.call(nil, writeMultipleCoilsRequest(address, coils)).err
 }
 
 // Request:
@@ -221,28 +229,10 @@ func (c *ClientHandler) WriteMultipleCoils(address uint16, coils []bool) (err er
 // Response:
 //  Function code         : 1 byte (0x03)
 //  Byte count            : 1 byte
-//  Register value        : Nx2 bytes
+//  Register value        : Nx2 bytes (Nx4 in Enron ranges)
 func (c *ClientHandler) ReadHoldingRegisters(address, quantity uint16) (values []uint16, err error) {
-	if quantity < 1 || quantity > 125 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 125)
-		return
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadHoldingRegisters,
-		Data:         dataBlock(address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	count := int(response.Data[0])
-	length := len(response.Data) - 1
-	if count != length {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, count)
-		return
-	}
-	values = bytesToWordArray(response.Data[1:])
-	return
+	res := c.call(nil, readRegistersRequest(c.enron, FuncCodeReadHoldingRegisters, address, quantity))
+	return res.registers, res.err
 }
 
 // Request:
@@ -252,28 +242,10 @@ func (c *ClientHandler) ReadHoldingRegisters(address, quantity uint16) (values [
 // Response:
 //  Function code         : 1 byte (0x04)
 //  Byte count            : 1 byte
-//  Input registers       : N bytes
+//  Input registers       : N bytes (Nx4 in Enron ranges)
 func (c *ClientHandler) ReadInputRegisters(address, quantity uint16) (values []uint16, err error) {
-	if quantity < 1 || quantity > 125 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 125)
-		return
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadInputRegisters,
-		Data:         dataBlock(address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	count := int(response.Data[0])
-	length := len(response.Data) - 1
-	if count != length {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, count)
-		return
-	}
-	values = bytesToWordArray(response.Data[1:])
-	return
+	res := c.call(nil, readRegistersRequest(c.enron, FuncCodeReadInputRegisters, address, quantity))
+	return res.registers, res.err
 }
 
 // Request:
@@ -285,31 +257,7 @@ func (c *ClientHandler) ReadInputRegisters(address, quantity uint16) (values []u
 //  Register address      : 2 bytes
 //  Register value        : 2 bytes
 func (c *ClientHandler) WriteSingleRegister(address, value uint16) (err error) {
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeWriteSingleRegister,
-		Data:         dataBlock(address, value),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	// Fixed response length
-	if len(response.Data) != 4 {
-		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
-		return
-	}
-	respValue := binary.BigEndian.Uint16(response.Data)
-	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
-		return
-	}
-	results := response.Data[2:]
-	respValue = binary.BigEndian.Uint16(results)
-	if value != respValue {
-		err = fmt.Errorf("modbus: response value '%v' does not match request '%v'", respValue, value)
-		return
-	}
-	return
+	return c.call(nil, writeSingleRegisterRequest(address, value)).err
 }
 
 // Request:
@@ -323,38 +271,7 @@ func (c *ClientHandler) WriteSingleRegister(address, value uint16) (err error) {
 //  Starting address      : 2 bytes
 //  Quantity of registers : 2 bytes
 func (c *ClientHandler) WriteMultipleRegisters(address uint16, values []uint16) (err error) {
-	count := len(values)
-	if count < 1 || count > 123 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", count, 1, 123)
-		return
-	}
-	quantity := uint16(count)
-
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeWriteMultipleRegisters,
-		Data:         dataBlockSuffix(dataBlock(values...), address, quantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	// Fixed response length
-	if len(response.Data) != 4 {
-		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
-		return
-	}
-	respValue := binary.BigEndian.Uint16(response.Data)
-	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
-		return
-	}
-	results := response.Data[2:]
-	respValue = binary.BigEndian.Uint16(results)
-	if quantity != respValue {
-		err = fmt.Errorf("modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
-		return
-	}
-	return
+	return c.call(nil, writeMultipleRegistersRequest(address, values)).err
 }
 
 // Request:
@@ -368,35 +285,7 @@ func (c *ClientHandler) WriteMultipleRegisters(address uint16, values []uint16)
 //  AND-mask              : 2 bytes
 //  OR-mask               : 2 bytes
 func (c *ClientHandler) MaskWriteRegister(address, andMask, orMask uint16) (err error) {
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeMaskWriteRegister,
-		Data:         dataBlock(address, andMask, orMask),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	// Fixed response length
-	if len(response.Data) != 6 {
-		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 6)
-		return
-	}
-	respValue := binary.BigEndian.Uint16(response.Data)
-	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
-		return
-	}
-	respValue = binary.BigEndian.Uint16(response.Data[2:])
-	if andMask != respValue {
-		err = fmt.Errorf("modbus: response AND-mask '%v' does not match request '%v'", respValue, andMask)
-		return
-	}
-	respValue = binary.BigEndian.Uint16(response.Data[4:])
-	if orMask != respValue {
-		err = fmt.Errorf("modbus: response OR-mask '%v' does not match request '%v'", respValue, orMask)
-		return
-	}
-	return
+	return c.call(nil, maskWriteRegisterRequest(address, andMask, orMask)).err
 }
 
 // Request:
@@ -412,29 +301,8 @@ func (c *ClientHandler) MaskWriteRegister(address, andMask, orMask uint16) (err
 //  Byte count            : 1 byte
 //  Read registers value  : Nx2 bytes
 func (c *ClientHandler) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (values []uint16, err error) {
-	if readQuantity < 1 || readQuantity > 125 {
-		err = fmt.Errorf("modbus: quantity to read '%v' must be between '%v' and '%v'", readQuantity, 1, 125)
-		return
-	}
-	if writeQuantity < 1 || writeQuantity > 121 {
-		err = fmt.Errorf("modbus: quantity to write '%v' must be between '%v' and '%v'", writeQuantity, 1, 121)
-		return
-	}
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadWriteMultipleRegisters,
-		Data:         dataBlockSuffix(value, readAddress, readQuantity, writeAddress, writeQuantity),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	count := int(response.Data[0])
-	if count != (len(response.Data) - 1) {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", len(response.Data)-1, count)
-		return
-	}
-	values = bytesToWordArray(response.Data[1:])
-	return
+	res := c.call(nil, readWriteMultipleRegistersRequest(readAddress, readQuantity, writeAddress, writeQuantity, value))
+	return res.registers, res.err
 }
 
 // Request:
@@ -447,36 +315,16 @@ func (c *ClientHandler) ReadWriteMultipleRegisters(readAddress, readQuantity, wr
 //  FIFO count            : 2 bytes (<=31)
 //  FIFO value register   : Nx2 bytes
 func (c *ClientHandler) ReadFIFOQueue(address uint16) (values []uint16, err error) {
-	request := ProtocolDataUnit{
-		FunctionCode: FuncCodeReadFIFOQueue,
-		Data:         dataBlock(address),
-	}
-	response, err := c.transceive(&request)
-	if err != nil {
-		return
-	}
-	if len(response.Data) < 4 {
-		err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 4)
-		return
-	}
-	count := int(binary.BigEndian.Uint16(response.Data))
-	if count != (len(response.Data) - 1) {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", len(response.Data)-1, count)
-		return
-	}
-	count = int(binary.BigEndian.Uint16(response.Data[2:]))
-	if count > 31 {
-		err = fmt.Errorf("modbus: fifo count '%v' is greater than expected '%v'", count, 31)
-		return
-	}
-	values = bytesToWordArray(response.Data[4:])
-	return
+	res := c.call(nil, readFIFOQueueRequest(address))
+	return res.registers, res.err
 }
 
 type ioStyle struct {
 	master  Client
 	address uint16
 	count   uint16
+	// lock serializes read-modify-write sequences of the master.
+	lock *sync.Mutex
 }
 
 type roBit ioStyle
@@ -512,6 +360,8 @@ func (io *rwBit) Clear() (err error) {
 }
 
 func (io *rwBit) Toggle() (err error) {
+	io.lock.Lock()
+	defer io.lock.Unlock()
 	state, err := io.Test()
 	if err != nil {
 		return
@@ -560,7 +410,7 @@ func (io *rwRegisters) Read() (values []uint16, err error) {
 
 func (io *rwRegisters) Write(values []uint16) (err error) {
 	if l := len(values); l > int(io.count) {
-		return fmt.Errorf("invalid length of words %d", l)
+		return errorf(ErrInvalidQuantity, "invalid length of words %d", l)
 	}
 	return io.master.WriteMultipleRegisters(io.address, values)
 }
@@ -570,7 +420,7 @@ func (c *ClientHandler) DiscreteInput(addr uint16) DiscreteInput {
 }
 
 func (c *ClientHandler) Coil(addr uint16) Coil {
-	return &rwBit{master: c, address: addr}
+	return &rwBit{master: c, address: addr, lock: &c.rmw}
 }
 
 func (c *ClientHandler) InputRegister(addr uint16) InputRegister {
@@ -578,15 +428,15 @@ func (c *ClientHandler) InputRegister(addr uint16) InputRegister {
 }
 
 func (c *ClientHandler) InputRegisters(addr, count uint16) InputRegisters {
-	return &roRegisters{c, addr, count}
+	return &roRegisters{master: c, address: addr, count: count}
 }
 
 func (c *ClientHandler) HoldingRegister(addr uint16) HoldingRegister {
-	return &rwRegister{master: c, address: addr}
+	return &rwRegister{master: c, address: addr, lock: &c.rmw}
 }
 
 func (c *ClientHandler) HoldingRegisters(addr, count uint16) HoldingRegisters {
-	return &rwRegisters{c, addr, count}
+	return &rwRegisters{master: c, address: addr, count: count}
 }
 
 func (io *rwRegisters) ReadString() (s string, err error) {
@@ -602,38 +452,217 @@ func (io *rwRegisters) WriteString(s string) (err error) {
 	return io.Write(bytesToWordArray([]byte(s)))
 }
 
-// send sends request and checks possible exception in the response.
-func (c *ClientHandler) transceive(request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
-	aduRequest, err := c.Packager.Encode(c.SlaveID, request)
-	if err != nil {
-		return
+// errorf creates an Error of the given kind for a request to the slave of c.
+func (c *ClientHandler) errorf(kind error, functionCode byte, format string, v ...interface{}) error {
+	e := errorf(kind, format, v...)
+	e.FunctionCode = functionCode
+	c.mu.Lock()
+	e.SlaveID = c.SlaveID
+	c.mu.Unlock()
+	return e
+}
+
+// annotate adds the request context to err if it is an Error.
+// The caller must hold c.mu.
+func (c *ClientHandler) annotate(err error, functionCode byte) {
+	annotate(err, c.SlaveID, functionCode)
+}
+
+// annotate adds the slave and function code of a request to err if it is an
+// Error.
+func annotate(err error, slaveID, functionCode byte) {
+	if e, ok := err.(*Error); ok {
+		e.SlaveID = slaveID
+		e.FunctionCode = functionCode
 	}
+}
 
+// slave returns the slave requests go to: to if not nil, otherwise the
+// slave of c.
+func (c *ClientHandler) slave(to *byte) byte {
+	if to != nil {
+		return *to
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	aduResponse, err := c.Packager.transceive(c.Transporter, c.Logger, aduRequest, c.Timeout)
+	return c.SlaveID
+}
+
+// call sends r to the slave of c, or to *to if not nil, and returns its
+// result.
+func (c *ClientHandler) call(to *byte, r *request) result {
+	res, _ := c.roundTrip(to, r)
+	return res
+}
+
+// roundTrip sends r to the slave of c, or to *to if not nil, and decodes the
+// response. It returns the slave r went to.
+func (c *ClientHandler) roundTrip(to *byte, r *request) (res result, slaveID byte) {
+	if r.err != nil {
+		slaveID = c.slave(to)
+		annotate(r.err, slaveID, r.pdu.FunctionCode)
+		return result{err: r.err}, slaveID
+	}
+	release, err := c.turn(r.pdu.FunctionCode)
 	if err != nil {
-		return
+		slaveID = c.slave(to)
+		annotate(err, slaveID, r.pdu.FunctionCode)
+		return result{err: err}, slaveID
+	}
+	defer release()
+	// The slave of c is read once the request has its turn, so waiting
+	// requests do not block on c.mu.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if to != nil {
+		defer func(saved byte) {
+			c.SlaveID = saved
+		}(c.SlaveID)
+		c.SlaveID = *to
+	}
+	res = c.send(r)
+	c.done(res.err)
+	return res, c.SlaveID
+}
+
+// send sends r, checks possible exception in the response and decodes it,
+// then reads back writes if configured with WithWriteVerify. The audit
+// record of a write has the outcome of both. The caller must hold c.mu.
+func (c *ClientHandler) send(r *request) (res result) {
+	request := &r.pdu
+	if c.isBroadcast() && !broadcastable(request.FunctionCode) {
+		err := errorf(ErrBroadcast, "modbus: function code '%v' cannot be broadcast", request.FunctionCode)
+		c.annotate(err, request.FunctionCode)
+		return result{err: err}
+	}
+	audit := c.auditStart(request, true)
+	defer func() {
+		c.auditEnd(audit, res.err)
+	}()
+	aduRequest, err := c.Packager.Encode(c.SlaveID, request)
+	if err != nil {
+		c.annotate(err, request.FunctionCode)
+		return result{err: err}
+	}
+	start := time.Now()
+	record := LogRecord{FunctionCode: request.FunctionCode}
+	var response *ProtocolDataUnit
+	for attempt := 0; ; attempt++ {
+		if response, err = c.exchange(record, request, aduRequest); err == nil || attempt >= c.retries {
+			break
+		}
+		record.Attempt = attempt + 1
+		record.Err = err
+		record.Message = "retrying request"
+		c.log(LogLevelInfo, record, aduRequest)
+		if c.retryBackoff > 0 {
+			time.Sleep(c.retryBackoff)
+		}
+	}
+	if res = c.complete(r, aduRequest, response, err, start); res.err == nil {
+		res.err = c.verifyWrite(r)
 	}
+	return
+}
 
-	if err = c.Packager.Verify(aduRequest, aduResponse); err != nil {
-		return
+// complete checks the response of r for exceptions and decodes it, then
+// logs and measures the outcome. The caller must hold c.mu.
+func (c *ClientHandler) complete(r *request, aduRequest []byte, response *ProtocolDataUnit, err error, start time.Time) (res result) {
+	request := &r.pdu
+	if err == nil {
+		// Check correct function code returned (exception)
+		if response.FunctionCode != request.FunctionCode {
+			err = responseError(response)
+		} else if response.Data == nil || len(response.Data) == 0 {
+			// Empty response
+			err = errorf(ErrLengthMismatch, "modbus: response data is empty")
+		} else {
+			res = r.decode(response)
+			err = res.err
+		}
 	}
-	response, err = c.Packager.Decode(aduResponse)
+	c.annotate(err, request.FunctionCode)
 	if err != nil {
-		return
+		record := LogRecord{
+			FunctionCode: request.FunctionCode,
+			Err:          err,
+			Duration:     time.Since(start),
+			Message:      "request failed",
+		}
+		c.log(LogLevelError, record, aduRequest)
 	}
-	// Check correct function code returned (exception)
-	if response.FunctionCode != request.FunctionCode {
-		err = responseError(response)
-		return
+	if c.metrics != nil {
+		labels := c.metricLabels(request.FunctionCode)
+		if e, ok := err.(*ModbusError); ok {
+			c.metrics.ObserveException(labels, e.ExceptionCode)
+		}
+		c.metrics.ObserveRequest(labels, time.Since(start), err)
 	}
-	if response.Data == nil || len(response.Data) == 0 {
-		// Empty response
-		err = fmt.Errorf("modbus: response data is empty")
-		return
+	if err != nil {
+		return result{err: err}
 	}
-	return
+	return res
+}
+
+// exchange performs one request/response round trip on the transporter, or
+// sends a broadcast, after the quiet time of the bus. The caller must hold
+// c.mu.
+func (c *ClientHandler) exchange(record LogRecord, request *ProtocolDataUnit, aduRequest []byte) (response *ProtocolDataUnit, err error) {
+	c.wait()
+	c.logSend(record, aduRequest)
+	if c.isBroadcast() {
+		response, err = c.broadcast(request, aduRequest)
+		c.quiet(request.FunctionCode, true)
+		return response, transportError(err)
+	}
+	start := time.Now()
+	aduResponse, err := c.Packager.transceive(c.Transporter, aduRequest, c.Timeout, c.enron)
+	c.quiet(request.FunctionCode, false)
+	return c.receive(record, aduRequest, aduResponse, err, start)
+}
+
+// logSend logs a request frame. The caller must hold c.mu.
+func (c *ClientHandler) logSend(record LogRecord, aduRequest []byte) {
+	record.Direction = DirectionSend
+	record.Message = "sending request"
+	c.log(LogLevelDebug, record, aduRequest)
+}
+
+// receive verifies and decodes the response to aduRequest, or handles the
+// error of receiving it. The caller must hold c.mu.
+func (c *ClientHandler) receive(record LogRecord, aduRequest, aduResponse []byte, err error, start time.Time) (*ProtocolDataUnit, error) {
+	var response *ProtocolDataUnit
+	if err == nil {
+		receive := record
+		receive.Direction = DirectionReceive
+		receive.Message = "received response"
+		receive.Duration = time.Since(start)
+		c.log(LogLevelDebug, receive, aduResponse)
+
+		if err = c.Packager.Verify(aduRequest, aduResponse); err == nil {
+			response, err = c.Packager.Decode(aduResponse)
+		}
+	} else {
+		err = transportError(err)
+	}
+	if err != nil && c.metrics != nil {
+		if errors.Is(err, ErrTimeout) {
+			c.metrics.ObserveTimeout(c.metricLabels(record.FunctionCode))
+		} else if errors.Is(err, ErrChecksum) {
+			c.metrics.ObserveChecksumError(c.metricLabels(record.FunctionCode))
+		}
+	}
+	if err != nil && c.reconnect {
+		// Drop the connection, the next attempt establishes a new one.
+		record.Err = err
+		record.Message = "closing connection to reconnect"
+		c.log(LogLevelInfo, record, aduRequest)
+		c.Transporter.Close()
+		if c.metrics != nil {
+			c.metrics.ObserveReconnect(c.metricLabels(record.FunctionCode))
+		}
+	}
+	return response, err
 }
 
 // dataBlock creates a sequence of uint16 data.
@@ -657,6 +686,17 @@ func dataBlockSuffix(suffix []byte, value ...uint16) []byte {
 	return data
 }
 
+// packBits packs coil states into bytes, the first one in the lowest bit.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, v := range bits {
+		if v {
+			packed[i>>3] |= 1 << (uint(i) & 7)
+		}
+	}
+	return packed
+}
+
 func responseError(response *ProtocolDataUnit) error {
 	mbError := &ModbusError{FunctionCode: response.FunctionCode}
 	if response.Data != nil && len(response.Data) > 0 {
@@ -665,12 +705,6 @@ func responseError(response *ProtocolDataUnit) error {
 	return mbError
 }
 
-func log(logger Logger, format string, v ...interface{}) {
-	if logger != nil {
-		logger.Output(2, fmt.Sprintf(format, v...))
-	}
-}
-
 func bytesToWordArray(bytes []byte) []uint16 {
 	l := len(bytes)
 	n := int(math.Ceil(float64(l) / 2))
@@ -678,7 +712,8 @@ func bytesToWordArray(bytes []byte) []uint16 {
 	for i := 0; i < n; i++ {
 		j := i * 2
 		if j+2 > l {
-			array[i] = uint16(bytes[j])
+			// Odd trailing byte, high byte first like the others
+			array[i] = uint16(bytes[j]) << 8
 		} else {
 			array[i] = binary.BigEndian.Uint16(bytes[j : j+2])
 		}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xft/modbus"
+)
+
+// encode converts textual values into registers.
+func (p *printer) encode(args []string) (words []uint16, err error) {
+	codec := modbus.NumberCodec(p.typ, p.order)
+	for _, arg := range args {
+		var v interface{}
+		switch p.typ {
+		case modbus.TypeFloat32, modbus.TypeFloat64:
+			v, err = strconv.ParseFloat(arg, 64)
+		case modbus.TypeInt16, modbus.TypeInt32, modbus.TypeInt64:
+			v, err = strconv.ParseInt(arg, 0, 64)
+		default:
+			v, err = strconv.ParseUint(arg, 0, 64)
+		}
+		if err != nil {
+			return
+		}
+		var w []uint16
+		if w, err = codec.Encode(v); err != nil {
+			return
+		}
+		words = append(words, w...)
+	}
+	return
+}
+
+// decode converts registers into typed values.
+func (p *printer) decode(words []uint16) (values []interface{}, err error) {
+	n := p.typ.Words()
+	if len(words)%n != 0 {
+		err = fmt.Errorf("%v registers cannot be decoded as %v", len(words), p.typ)
+		return
+	}
+	codec := modbus.NumberCodec(p.typ, p.order)
+	for i := 0; i < len(words); i += n {
+		var v interface{}
+		if v, err = codec.Decode(words[i : i+n]); err != nil {
+			return
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+// registerCount returns the number of registers holding count values,
+// which must fit in one read request.
+func (p *printer) registerCount(count uint16) (uint16, error) {
+	n := int(count) * p.typ.Words()
+	if n > modbus.MaxReadRegisters {
+		return 0, fmt.Errorf("%v values of type '%v' take %v registers, more than '%v'", count, p.typ, n, modbus.MaxReadRegisters)
+	}
+	return uint16(n), nil
+}
+
+// printer writes results in the selected output format.
+type printer struct {
+	w      io.Writer
+	format string
+	typ    modbus.NumberType
+	order  modbus.WordOrder
+}
+
+func (p *printer) registers(address uint16, words []uint16) error {
+	if p.format == "hex" {
+		hex := make([]string, len(words))
+		for i, w := range words {
+			hex[i] = fmt.Sprintf("%04x", w)
+		}
+		_, err := fmt.Fprintln(p.w, strings.Join(hex, " "))
+		return err
+	}
+	values, err := p.decode(words)
+	if err != nil {
+		return err
+	}
+	return p.print(address, p.typ.Words(), p.typ.String(), values)
+}
+
+func (p *printer) bits(address uint16, bits []bool) error {
+	values := make([]interface{}, len(bits))
+	for i, b := range bits {
+		if p.format == "json" {
+			values[i] = b
+		} else if b {
+			values[i] = 1
+		} else {
+			values[i] = 0
+		}
+	}
+	if p.format == "hex" {
+		_, err := fmt.Fprintln(p.w, values...)
+		return err
+	}
+	return p.print(address, 1, "bool", values)
+}
+
+func (p *printer) text(address uint16, s string) error {
+	if p.format == "json" {
+		return p.json(map[string]interface{}{"address": address, "type": "string", "value": s})
+	}
+	_, err := fmt.Fprintln(p.w, s)
+	return err
+}
+
+func (p *printer) print(address uint16, stride int, typ string, values []interface{}) error {
+	if p.format == "json" {
+		return p.json(map[string]interface{}{"address": address, "type": typ, "values": values})
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ADDRESS\tVALUE\t")
+	for i, v := range values {
+		fmt.Fprintf(tw, "%d\t%v\t\n", int(address)+i*stride, v)
+	}
+	return tw.Flush()
+}
+
+func (p *printer) json(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
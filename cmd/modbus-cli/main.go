@@ -0,0 +1,342 @@
+// Command modbus-cli performs ad-hoc reads and writes against a Modbus device.
+//
+// Usage:
+//
+//	modbus-cli [flags] URL COMMAND [ARGS...]
+//
+// Example:
+//
+//	modbus-cli -type float32 -order cdab tcp://10.0.0.5?slave=3 read-holding-registers 3000 4
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xft/modbus"
+)
+
+const usageText = `Usage: modbus-cli [flags] URL COMMAND [ARGS...]
+
+URL:
+  tcp://HOST[:PORT]
+  rtuovertcp://HOST:PORT
+  asciiovertcp://HOST:PORT
+  rtu://DEVICE?baud=9600&databits=8&parity=E&stopbits=1
+  ascii://DEVICE?baud=9600&databits=7&parity=E&stopbits=1
+  All schemes accept unit=ID and timeout=DURATION, e.g. tcp://plc?unit=2&timeout=1s
+
+Commands:
+  read-discrete-inputs ADDRESS QUANTITY
+  read-coils ADDRESS QUANTITY
+  write-single-coil ADDRESS VALUE
+  write-multiple-coils ADDRESS VALUE...
+  toggle-coil ADDRESS
+  read-holding-registers ADDRESS QUANTITY
+  read-input-registers ADDRESS QUANTITY
+  write-single-register ADDRESS VALUE
+  write-multiple-registers ADDRESS VALUE...
+  read-write-multiple-registers READ-ADDRESS READ-QUANTITY WRITE-ADDRESS VALUE...
+  mask-write-register ADDRESS AND-MASK OR-MASK
+  read-fifo-queue ADDRESS
+  read-holding-string ADDRESS COUNT
+  read-input-string ADDRESS COUNT
+  write-string ADDRESS COUNT TEXT
+
+QUANTITY is the number of -type values, not registers. Numbers may be given
+in decimal or with a 0x prefix. Coil values are 1/0, true/false or on/off.
+
+Flags:
+`
+
+type command struct {
+	args int // minimum number of arguments
+	run  func(c *modbus.ClientHandler, p *printer, args []string) error
+}
+
+var commands = map[string]command{
+	"read-discrete-inputs": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, quantity, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		inputs, err := c.ReadDiscreteInputs(address, quantity)
+		if err != nil {
+			return err
+		}
+		return p.bits(address, inputs)
+	}},
+	"read-coils": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, quantity, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		coils, err := c.ReadCoils(address, quantity)
+		if err != nil {
+			return err
+		}
+		return p.bits(address, coils)
+	}},
+	"write-single-coil": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		coils, err := parseBools(args[1:2])
+		if err != nil {
+			return err
+		}
+		return c.WriteSingleCoil(address, coils[0])
+	}},
+	"write-multiple-coils": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		coils, err := parseBools(args[1:])
+		if err != nil {
+			return err
+		}
+		return c.WriteMultipleCoils(address, coils)
+	}},
+	"toggle-coil": {1, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		return c.Coil(address).Toggle()
+	}},
+	"read-holding-registers": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, quantity, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		if quantity, err = p.registerCount(quantity); err != nil {
+			return err
+		}
+		values, err := c.ReadHoldingRegisters(address, quantity)
+		if err != nil {
+			return err
+		}
+		return p.registers(address, values)
+	}},
+	"read-input-registers": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, quantity, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		if quantity, err = p.registerCount(quantity); err != nil {
+			return err
+		}
+		values, err := c.ReadInputRegisters(address, quantity)
+		if err != nil {
+			return err
+		}
+		return p.registers(address, values)
+	}},
+	"write-single-register": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		values, err := p.encode(args[1:2])
+		if err != nil {
+			return err
+		}
+		if len(values) != 1 {
+			return fmt.Errorf("type '%v' does not fit in a single register", p.typ)
+		}
+		return c.WriteSingleRegister(address, values[0])
+	}},
+	"write-multiple-registers": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		values, err := p.encode(args[1:])
+		if err != nil {
+			return err
+		}
+		return c.WriteMultipleRegisters(address, values)
+	}},
+	"read-write-multiple-registers": {4, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		readAddress, readQuantity, err := parseRange(args[0:2])
+		if err != nil {
+			return err
+		}
+		writeAddress, err := parseUint16(args[2])
+		if err != nil {
+			return err
+		}
+		words, err := p.encode(args[3:])
+		if err != nil {
+			return err
+		}
+		if readQuantity, err = p.registerCount(readQuantity); err != nil {
+			return err
+		}
+		values, err := c.ReadWriteMultipleRegisters(readAddress, readQuantity,
+			writeAddress, uint16(len(words)), wordsToBytes(words))
+		if err != nil {
+			return err
+		}
+		return p.registers(readAddress, values)
+	}},
+	"mask-write-register": {3, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		andMask, err := parseUint16(args[1])
+		if err != nil {
+			return err
+		}
+		orMask, err := parseUint16(args[2])
+		if err != nil {
+			return err
+		}
+		return c.MaskWriteRegister(address, andMask, orMask)
+	}},
+	"read-fifo-queue": {1, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, err := parseUint16(args[0])
+		if err != nil {
+			return err
+		}
+		values, err := c.ReadFIFOQueue(address)
+		if err != nil {
+			return err
+		}
+		return p.registers(address, values)
+	}},
+	"read-holding-string": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, count, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		s, err := c.HoldingRegisters(address, count).ReadString()
+		if err != nil {
+			return err
+		}
+		return p.text(address, s)
+	}},
+	"read-input-string": {2, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, count, err := parseRange(args)
+		if err != nil {
+			return err
+		}
+		s, err := c.InputRegisters(address, count).ReadString()
+		if err != nil {
+			return err
+		}
+		return p.text(address, s)
+	}},
+	"write-string": {3, func(c *modbus.ClientHandler, p *printer, args []string) error {
+		address, count, err := parseRange(args[0:2])
+		if err != nil {
+			return err
+		}
+		return c.HoldingRegisters(address, count).WriteString(strings.Join(args[2:], " "))
+	}},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("modbus-cli: ")
+
+	format := flag.String("format", "table", "output format: table, hex or json")
+	typeName := flag.String("type", "uint16", "register value type: uint16, int16, uint32, int32, float32, uint64, int64 or float64")
+	orderName := flag.String("order", "abcd", "byte order of multi-register values: abcd, cdab, badc or dcba")
+	slave := flag.Int("slave", -1, "slave id, overrides the unit in the URL")
+	verbose := flag.Bool("v", false, "dump frames to stderr")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usageText)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[1]]
+	if !ok || len(args)-2 < cmd.args {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	p := &printer{w: os.Stdout, format: *format}
+	switch p.format {
+	case "table", "hex", "json":
+	default:
+		log.Fatalf("unknown format '%v'", p.format)
+	}
+	var err error
+	if p.typ, err = modbus.ParseNumberType(*typeName); err != nil {
+		log.Fatal(err)
+	}
+	if p.order, err = modbus.ParseWordOrder(*orderName); err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := modbus.Dial(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	if *slave >= 0 {
+		client.SetSlaveID(byte(*slave))
+	}
+	if *verbose {
+		client.SetLogger(log.New(os.Stderr, "", log.Ltime|log.Lmicroseconds))
+	}
+
+	if err = cmd.run(client, p, args[2:]); err != nil {
+		client.Close()
+		log.Fatal(err)
+	}
+}
+
+func parseUint16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%v'", s)
+	}
+	return uint16(v), nil
+}
+
+func parseRange(args []string) (address, quantity uint16, err error) {
+	if address, err = parseUint16(args[0]); err != nil {
+		return
+	}
+	quantity, err = parseUint16(args[1])
+	return
+}
+
+func parseBools(args []string) (values []bool, err error) {
+	values = make([]bool, len(args))
+	for i, arg := range args {
+		switch strings.ToLower(arg) {
+		case "1", "true", "on":
+			values[i] = true
+		case "0", "false", "off":
+		default:
+			err = fmt.Errorf("invalid coil value '%v'", arg)
+			return
+		}
+	}
+	return
+}
+
+func wordsToBytes(words []uint16) []byte {
+	b := make([]byte, 2*len(words))
+	for i, w := range words {
+		b[2*i] = byte(w >> 8)
+		b[2*i+1] = byte(w)
+	}
+	return b
+}
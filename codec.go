@@ -0,0 +1,365 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Codec converts a value to and from consecutive registers.
+type Codec interface {
+	// Words returns the number of registers of a value.
+	Words() int
+	// Decode converts Words() registers to a value.
+	Decode(words []uint16) (interface{}, error)
+	// Encode converts a value to Words() registers.
+	Encode(v interface{}) ([]uint16, error)
+}
+
+// WordOrder is the byte layout of multi-register values, named after the
+// position of the bytes of the big-endian value 0xAABBCCDD.
+type WordOrder byte
+
+const (
+	// OrderABCD is big-endian, the Modbus default.
+	OrderABCD WordOrder = iota
+	// OrderCDAB swaps the registers.
+	OrderCDAB
+	// OrderBADC swaps the bytes of each register.
+	OrderBADC
+	// OrderDCBA is little-endian.
+	OrderDCBA
+)
+
+// ParseWordOrder parses abcd, cdab, badc or dcba.
+func ParseWordOrder(s string) (WordOrder, error) {
+	switch strings.ToLower(s) {
+	case "abcd":
+		return OrderABCD, nil
+	case "cdab":
+		return OrderCDAB, nil
+	case "badc":
+		return OrderBADC, nil
+	case "dcba":
+		return OrderDCBA, nil
+	}
+	return 0, fmt.Errorf("modbus: unknown word order '%v'", s)
+}
+
+func (o WordOrder) String() string {
+	switch o {
+	case OrderABCD:
+		return "abcd"
+	case OrderCDAB:
+		return "cdab"
+	case OrderBADC:
+		return "badc"
+	case OrderDCBA:
+		return "dcba"
+	}
+	return fmt.Sprintf("WordOrder(%d)", byte(o))
+}
+
+func (o WordOrder) swapWords() bool {
+	return o == OrderCDAB || o == OrderDCBA
+}
+
+func (o WordOrder) swapBytes() bool {
+	return o == OrderBADC || o == OrderDCBA
+}
+
+// bytes converts registers to a big-endian byte sequence.
+func (o WordOrder) bytes(words []uint16) []byte {
+	n := len(words)
+	b := make([]byte, 2*n)
+	for i, w := range words {
+		if o.swapWords() {
+			i = n - 1 - i
+		}
+		if o.swapBytes() {
+			w = w<<8 | w>>8
+		}
+		binary.BigEndian.PutUint16(b[2*i:], w)
+	}
+	return b
+}
+
+// words converts a big-endian byte sequence to registers.
+func (o WordOrder) words(b []byte) []uint16 {
+	n := len(b) / 2
+	words := make([]uint16, n)
+	for i := range words {
+		w := binary.BigEndian.Uint16(b[2*i:])
+		if o.swapBytes() {
+			w = w<<8 | w>>8
+		}
+		if o.swapWords() {
+			words[n-1-i] = w
+		} else {
+			words[i] = w
+		}
+	}
+	return words
+}
+
+// NumberType is a numeric type stored in one or more registers.
+type NumberType byte
+
+const (
+	TypeUint16 NumberType = iota + 1
+	TypeInt16
+	TypeUint32
+	TypeInt32
+	TypeFloat32
+	TypeUint64
+	TypeInt64
+	TypeFloat64
+)
+
+var numberTypeNames = map[NumberType]string{
+	TypeUint16:  "uint16",
+	TypeInt16:   "int16",
+	TypeUint32:  "uint32",
+	TypeInt32:   "int32",
+	TypeFloat32: "float32",
+	TypeUint64:  "uint64",
+	TypeInt64:   "int64",
+	TypeFloat64: "float64",
+}
+
+// ParseNumberType parses the Go name of a number type, such as float32.
+func ParseNumberType(s string) (NumberType, error) {
+	for t, name := range numberTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("modbus: unknown number type '%v'", s)
+}
+
+func (t NumberType) String() string {
+	if name, ok := numberTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("NumberType(%d)", byte(t))
+}
+
+// Words returns the number of registers of t.
+func (t NumberType) Words() int {
+	switch t {
+	case TypeUint16, TypeInt16:
+		return 1
+	case TypeUint32, TypeInt32, TypeFloat32:
+		return 2
+	}
+	return 4
+}
+
+func (t NumberType) signed() bool {
+	return t == TypeInt16 || t == TypeInt32 || t == TypeInt64
+}
+
+func (t NumberType) float() bool {
+	return t == TypeFloat32 || t == TypeFloat64
+}
+
+// NumberCodec returns the codec of numbers of type t laid out in order.
+// Decode returns the Go type named by t, Encode accepts any integer or
+// float within the range of t; floats must be integral for integer types.
+func NumberCodec(t NumberType, order WordOrder) Codec {
+	return numberCodec{t, order}
+}
+
+type numberCodec struct {
+	typ   NumberType
+	order WordOrder
+}
+
+func (c numberCodec) Words() int {
+	return c.typ.Words()
+}
+
+func (c numberCodec) Decode(words []uint16) (v interface{}, err error) {
+	if len(words) != c.Words() {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as %v", len(words), c.typ)
+		return
+	}
+	b := c.order.bytes(words)
+	switch c.typ {
+	case TypeUint16:
+		v = binary.BigEndian.Uint16(b)
+	case TypeInt16:
+		v = int16(binary.BigEndian.Uint16(b))
+	case TypeUint32:
+		v = binary.BigEndian.Uint32(b)
+	case TypeInt32:
+		v = int32(binary.BigEndian.Uint32(b))
+	case TypeFloat32:
+		v = math.Float32frombits(binary.BigEndian.Uint32(b))
+	case TypeUint64:
+		v = binary.BigEndian.Uint64(b)
+	case TypeInt64:
+		v = int64(binary.BigEndian.Uint64(b))
+	case TypeFloat64:
+		v = math.Float64frombits(binary.BigEndian.Uint64(b))
+	default:
+		err = fmt.Errorf("modbus: unknown number type '%v'", c.typ)
+	}
+	return
+}
+
+func (c numberCodec) Encode(v interface{}) (words []uint16, err error) {
+	n, ok := toNumber(v)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as %v", v, c.typ)
+		return
+	}
+	bits := uint(16 * c.Words())
+	var raw uint64
+	switch {
+	case c.typ == TypeFloat32:
+		raw = uint64(math.Float32bits(float32(n.float())))
+	case c.typ == TypeFloat64:
+		raw = math.Float64bits(n.float())
+	case c.typ.signed():
+		i, ok := n.int(bits)
+		if !ok {
+			err = fmt.Errorf("modbus: value '%v' overflows %v", v, c.typ)
+			return
+		}
+		raw = uint64(i)
+	default:
+		u, ok := n.uint(bits)
+		if !ok {
+			err = fmt.Errorf("modbus: value '%v' overflows %v", v, c.typ)
+			return
+		}
+		raw = u
+	}
+	b := make([]byte, 2*c.Words())
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(raw)
+		raw >>= 8
+	}
+	words = c.order.words(b)
+	return
+}
+
+// number holds a value of any Go integer or float type.
+type number struct {
+	kind reflect.Kind // Int64, Uint64 or Float64
+	i    int64
+	u    uint64
+	f    float64
+}
+
+func toNumber(v interface{}) (n number, ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n.kind, n.i = reflect.Int64, rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n.kind, n.u = reflect.Uint64, rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		n.kind, n.f = reflect.Float64, rv.Float()
+	default:
+		return
+	}
+	return n, true
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case reflect.Int64:
+		return float64(n.i)
+	case reflect.Uint64:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+// int returns n as a signed integer of the given size in bits.
+func (n number) int(bits uint) (int64, bool) {
+	max := int64(1)<<(bits-1) - 1
+	min := -max - 1
+	switch n.kind {
+	case reflect.Int64:
+		return n.i, n.i >= min && n.i <= max
+	case reflect.Uint64:
+		return int64(n.u), n.u <= uint64(max)
+	}
+	return int64(n.f), n.f == math.Trunc(n.f) && n.f >= float64(min) && n.f < -float64(min)
+}
+
+// uint returns n as an unsigned integer of the given size in bits.
+func (n number) uint(bits uint) (uint64, bool) {
+	max := uint64(1)<<(bits-1)<<1 - 1
+	switch n.kind {
+	case reflect.Int64:
+		return uint64(n.i), n.i >= 0 && uint64(n.i) <= max
+	case reflect.Uint64:
+		return n.u, n.u <= max
+	}
+	return uint64(n.f), n.f == math.Trunc(n.f) && n.f >= 0 && n.f < math.Ldexp(1, int(bits))
+}
+
+// RawCodec returns the codec of a plain []uint16 of the given length.
+func RawCodec(words int) Codec {
+	return rawCodec{words}
+}
+
+type rawCodec struct {
+	words int
+}
+
+func (c rawCodec) Words() int {
+	return c.words
+}
+
+func (c rawCodec) Decode(words []uint16) (interface{}, error) {
+	return append([]uint16(nil), words...), nil
+}
+
+func (c rawCodec) Encode(v interface{}) ([]uint16, error) {
+	words, ok := v.([]uint16)
+	if !ok {
+		return nil, fmt.Errorf("modbus: %T cannot be encoded as []uint16", v)
+	}
+	if len(words) != c.words {
+		return nil, fmt.Errorf("modbus: %v registers given, expected %v", len(words), c.words)
+	}
+	return append([]uint16(nil), words...), nil
+}
+
+// readAs reads the registers of codec at address with read and decodes
+// them, checking that they fit in count registers.
+func readAs(read func(address, quantity uint16) ([]uint16, error), address, count uint16, codec Codec) (v interface{}, err error) {
+	n := codec.Words()
+	if n < 1 || n > int(count) {
+		err = errorf(ErrInvalidQuantity, "modbus: value of %v registers does not fit in %v", n, count)
+		return
+	}
+	words, err := read(address, uint16(n))
+	if err != nil {
+		return
+	}
+	return codec.Decode(words)
+}
+
+func (io *roRegisters) ReadAs(codec Codec) (interface{}, error) {
+	return readAs(io.master.ReadInputRegisters, io.address, io.count, codec)
+}
+
+func (io *rwRegisters) ReadAs(codec Codec) (interface{}, error) {
+	return readAs(io.master.ReadHoldingRegisters, io.address, io.count, codec)
+}
+
+func (io *rwRegisters) WriteAs(codec Codec, v interface{}) (err error) {
+	words, err := codec.Encode(v)
+	if err != nil {
+		return
+	}
+	return io.Write(words)
+}
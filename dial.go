@@ -0,0 +1,186 @@
+package modbus
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tcpDefaultPort    = "502"
+	tcpDefaultTimeout = 10 * time.Second
+
+	serialDefaultBaudRate = 115200
+	serialDefaultDataBits = 8
+	serialDefaultParity   = "N"
+	serialDefaultStopBits = 1
+	serialDefaultTimeout  = 5 * time.Second
+)
+
+// Dial creates a client handler from a connection URL:
+//
+//	tcp://host[:port]
+//	rtuovertcp://host:port
+//	asciiovertcp://host:port
+//	rtu:///dev/ttyUSB0?baud=9600&databits=8&parity=E&stopbits=1
+//	ascii://COM3?baud=9600&databits=7&parity=E
+//
+// All schemes accept the query parameters timeout (response timeout, e.g.
+// "500ms") and unit (slave id, also spelled slave). TCP based schemes accept
+// connect_timeout; serial ones accept baud, databits, parity (N, E, O, M, S
+// or none, even, odd, mark, space) and stopbits. The TCP port defaults to
+// 502. Options are applied after the URL settings.
+//
+// Like the other constructors, Dial does not open the connection; it is
+// established by Connect or the first request.
+func Dial(rawurl string, opts ...Option) (client *ClientHandler, err error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		err = fmt.Errorf("modbus: invalid url '%v': %v", rawurl, err)
+		return
+	}
+	p := &urlParams{values: u.Query()}
+
+	var packager Packager
+	var transporter Transporter
+	var settings []Option
+	switch u.Scheme {
+	case "tcp", "rtuovertcp", "asciiovertcp":
+		address := u.Host
+		if u.Hostname() == "" {
+			err = fmt.Errorf("modbus: missing host in '%v'", rawurl)
+			return
+		}
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), tcpDefaultPort)
+		}
+		timeout := p.duration("timeout", tcpDefaultTimeout)
+		connectTimeout := p.duration("connect_timeout", timeout)
+		transporter = NewTCPAddrTransport(address, connectTimeout)
+		settings = append(settings, WithTimeout(timeout))
+		switch u.Scheme {
+		case "tcp":
+			packager = &TCPPackager{}
+		case "rtuovertcp":
+			packager = &RTUPackager{}
+		default:
+			packager = &ASCIIPackager{}
+		}
+	case "rtu", "ascii":
+		device := u.Host + u.Path
+		if device == "" {
+			err = fmt.Errorf("modbus: missing serial device in '%v'", rawurl)
+			return
+		}
+		baudRate := p.integer("baud", serialDefaultBaudRate, 1, 4000000)
+		dataBits := p.integer("databits", serialDefaultDataBits, 5, 8)
+		parity := p.parity("parity", serialDefaultParity)
+		stopBits := p.integer("stopbits", serialDefaultStopBits, 1, 2)
+		timeout := p.duration("timeout", serialDefaultTimeout)
+		transporter = NewSerialTransport(device, baudRate, dataBits, parity, stopBits, timeout)
+		settings = append(settings, WithTimeout(timeout))
+		if u.Scheme == "rtu" {
+			packager = &RTUPackager{}
+		} else {
+			packager = &ASCIIPackager{}
+		}
+	default:
+		err = fmt.Errorf("modbus: unsupported scheme '%v' in '%v'", u.Scheme, rawurl)
+		return
+	}
+
+	unit := p.integer("unit", 0, 0, 255)
+	unit = p.integer("slave", unit, 0, 255)
+	settings = append(settings, WithUnitID(byte(unit)))
+
+	if err = p.finish(); err != nil {
+		return
+	}
+	return NewClientHandler(packager, transporter, append(settings, opts...)...)
+}
+
+// urlParams consumes query parameters, remembering the first invalid value
+// and which parameters were used so that typos are reported.
+type urlParams struct {
+	values url.Values
+	err    error
+}
+
+func (p *urlParams) get(name string) string {
+	v := p.values.Get(name)
+	delete(p.values, name)
+	return v
+}
+
+func (p *urlParams) fail(name, value string, reason string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("modbus: invalid %v '%v': %v", name, value, reason)
+	}
+}
+
+func (p *urlParams) integer(name string, def, min, max int) int {
+	s := p.get(name)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		p.fail(name, s, "not a number")
+		return def
+	}
+	if v < min || v > max {
+		p.fail(name, s, fmt.Sprintf("must be between '%v' and '%v'", min, max))
+		return def
+	}
+	return v
+}
+
+func (p *urlParams) duration(name string, def time.Duration) time.Duration {
+	s := p.get(name)
+	if s == "" {
+		return def
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		p.fail(name, s, "not a duration")
+		return def
+	}
+	if v < 0 {
+		p.fail(name, s, "must not be negative")
+		return def
+	}
+	return v
+}
+
+func (p *urlParams) parity(name string, def string) string {
+	s := p.get(name)
+	switch strings.ToLower(s) {
+	case "":
+		return def
+	case "n", "none":
+		return "N"
+	case "e", "even":
+		return "E"
+	case "o", "odd":
+		return "O"
+	case "m", "mark":
+		return "M"
+	case "s", "space":
+		return "S"
+	}
+	p.fail(name, s, "must be one of N, E, O, M or S")
+	return def
+}
+
+func (p *urlParams) finish() error {
+	if p.err != nil {
+		return p.err
+	}
+	for name := range p.values {
+		return fmt.Errorf("modbus: unknown url parameter '%v'", name)
+	}
+	return nil
+}
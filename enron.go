@@ -0,0 +1,130 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxReadEnron is the maximum quantity of 32-bit registers read at once.
+const MaxReadEnron = 62
+
+// EnronRange is an inclusive range of register addresses that hold 32-bit
+// values, as in the Enron (Daniel) Modbus extension used by flow computers,
+// typically 5001-5999 for integers and 7001-7999 for floats. A quantity of
+// one reads one 32-bit register, returned as two words, high word first.
+type EnronRange struct {
+	Start, End uint16
+}
+
+// enronRanges are the 32-bit register ranges of a client.
+type enronRanges []EnronRange
+
+// lookup reports whether quantity registers at address are 32-bit, and
+// whether they are all of the same width.
+func (rs enronRanges) lookup(address, quantity uint16) (wide, uniform bool) {
+	last := int(address) + int(quantity) - 1
+	for _, r := range rs {
+		if address >= r.Start && address <= r.End {
+			return true, last <= int(r.End)
+		}
+		if int(r.Start) > int(address) && int(r.Start) <= last {
+			return false, false
+		}
+	}
+	return false, true
+}
+
+// overlaps reports whether any of quantity registers at address is 32-bit.
+func (rs enronRanges) overlaps(address, quantity uint16) bool {
+	wide, uniform := rs.lookup(address, quantity)
+	return wide || !uniform
+}
+
+// span returns how many of quantity registers at address one read request
+// takes: those of the width of the first, at most max 16-bit or MaxReadEnron
+// 32-bit ones.
+func (rs enronRanges) span(address uint16, quantity, max int) int {
+	n := quantity
+	for _, r := range rs {
+		if address >= r.Start && address <= r.End {
+			max = MaxReadEnron
+			if rest := int(r.End) - int(address) + 1; rest < n {
+				n = rest
+			}
+			break
+		}
+		if r.Start > address {
+			if rest := int(r.Start) - int(address); rest < n {
+				n = rest
+			}
+			break
+		}
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// quantity reports whether the registers of a read request are 32-bit and
+// checks the quantity against the limit of their width.
+func (rs enronRanges) quantity(functionCode byte, address, quantity uint16) (wide bool, err error) {
+	wide, uniform := rs.lookup(address, quantity)
+	if !uniform {
+		err = requestErrorf(ErrInvalidQuantity, functionCode, "modbus: registers '%v' to '%v' mix 16-bit and 32-bit registers", address, int(address)+int(quantity)-1)
+		return
+	}
+	max := uint16(MaxReadRegisters)
+	if wide {
+		max = MaxReadEnron
+	}
+	if quantity < 1 || quantity > max {
+		err = requestErrorf(ErrInvalidQuantity, functionCode, "modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, max)
+	}
+	return
+}
+
+// ReadEnronRegisters reads quantity 32-bit holding registers of an Enron
+// range configured with WithEnronRanges.
+func (c *ClientHandler) ReadEnronRegisters(address, quantity uint16) (values []uint32, err error) {
+	if wide, _ := c.enron.lookup(address, quantity); !wide {
+		err = c.errorf(ErrInvalidQuantity, FuncCodeReadHoldingRegisters, "modbus: address '%v' is not in an Enron range", address)
+		return
+	}
+	words, err := c.ReadHoldingRegisters(address, quantity)
+	if err != nil {
+		return
+	}
+	values = make([]uint32, quantity)
+	for i := range values {
+		values[i] = uint32(words[2*i])<<16 | uint32(words[2*i+1])
+	}
+	return
+}
+
+// ReadEnronFloats reads quantity 32-bit holding registers of an Enron range
+// as IEEE 754 floats.
+func (c *ClientHandler) ReadEnronFloats(address, quantity uint16) (values []float32, err error) {
+	raw, err := c.ReadEnronRegisters(address, quantity)
+	if err != nil {
+		return
+	}
+	values = make([]float32, len(raw))
+	for i, u := range raw {
+		values[i] = math.Float32frombits(u)
+	}
+	return
+}
+
+// validEnronRanges checks that ranges are ordered and do not overlap.
+func validEnronRanges(ranges []EnronRange) error {
+	for i, r := range ranges {
+		if r.Start > r.End {
+			return fmt.Errorf("modbus: Enron range '%v' to '%v' is empty", r.Start, r.End)
+		}
+		if i > 0 && r.Start <= ranges[i-1].End {
+			return fmt.Errorf("modbus: Enron ranges must be ordered and must not overlap")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of request failures, to be tested with errors.Is. A request
+// returns an *Error of one of these kinds, a *ModbusError for an exception
+// response or a *WriteVerifyError for a write that read back different.
+// The *Large methods wrap these in a *ChunkError.
+var (
+	// ErrConnection means the transporter failed to connect, send or
+	// receive, e.g. because the device closed the connection. Err holds
+	// the error of the transporter.
+	ErrConnection = errors.New("modbus: connection failed")
+	// ErrTimeout means no (complete) response arrived in time, typically a
+	// cabling, addressing or power problem.
+	ErrTimeout = errors.New("modbus: timeout")
+	// ErrChecksum means the CRC or LRC of a response is wrong, typically
+	// noise or a wrong serial configuration.
+	ErrChecksum = errors.New("modbus: checksum mismatch")
+	// ErrFraming means a response is not a well-formed frame.
+	ErrFraming = errors.New("modbus: invalid frame")
+	// ErrLengthMismatch means a length or byte count in a response does
+	// not match its data or the request.
+	ErrLengthMismatch = errors.New("modbus: length mismatch")
+	// ErrUnitIDMismatch means the response came from another slave.
+	ErrUnitIDMismatch = errors.New("modbus: unit id mismatch")
+	// ErrTransactionMismatch means a Modbus TCP response belongs to
+	// another request, e.g. one that timed out before.
+	ErrTransactionMismatch = errors.New("modbus: transaction id mismatch")
+	// ErrEchoMismatch means a write response does not echo the address,
+	// value or quantity of the request.
+	ErrEchoMismatch = errors.New("modbus: response does not match request")
+	// ErrInvalidQuantity means a request was rejected before being sent
+	// because a quantity or length is out of range.
+	ErrInvalidQuantity = errors.New("modbus: invalid quantity")
+	// ErrBroadcast means a request to slave 0 of a serial bus, received by
+	// all slaves and answered by none, was rejected before being sent
+	// because it does not write.
+	ErrBroadcast = errors.New("modbus: function cannot be broadcast")
+	// ErrWriteDenied means a Guard rejected a write before it was sent.
+	ErrWriteDenied = errors.New("modbus: write denied")
+	// ErrDeadlineExceeded means a request waited in the queue of the
+	// client past its deadline and was dropped before being sent.
+	ErrDeadlineExceeded = errors.New("modbus: queue deadline exceeded")
+)
+
+// Error describes a failed request. errors.Is reports true for its Kind,
+// errors.As finds it to inspect the context.
+type Error struct {
+	// Kind is one of the Err* variables of this package.
+	Kind         error
+	SlaveID      byte
+	FunctionCode byte
+	// ADU is the raw response frame, when one was received.
+	ADU []byte
+	// Err is the underlying error, such as a net.Error, or nil.
+	Err error
+
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether e is a timeout, as net.Error does.
+func (e *Error) Timeout() bool {
+	return e.Kind == ErrTimeout
+}
+
+// errorf creates an Error of the given kind.
+func errorf(kind error, format string, v ...interface{}) *Error {
+	return &Error{Kind: kind, msg: fmt.Sprintf(format, v...)}
+}
+
+// frameErrorf creates an Error of the given kind carrying the response ADU.
+func frameErrorf(kind error, adu []byte, format string, v ...interface{}) *Error {
+	e := errorf(kind, format, v...)
+	e.ADU = adu
+	return e
+}
+
+// transportError wraps an error of the transporter as ErrTimeout or
+// ErrConnection, unless it already is an Error.
+func transportError(err error) error {
+	if _, ok := err.(*Error); ok || err == nil {
+		return err
+	}
+	if isTimeout(err) {
+		return wrapError(ErrTimeout, err)
+	}
+	return wrapError(ErrConnection, err)
+}
+
+// wrapError creates an Error of the given kind around err.
+func wrapError(kind error, err error) *Error {
+	return &Error{Kind: kind, Err: err, msg: err.Error()}
+}
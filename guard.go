@@ -0,0 +1,289 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// GuardRange allows writes to an inclusive range of coils or holding
+// registers of a slave.
+type GuardRange struct {
+	SlaveID    byte
+	Table      Table
+	Start, End uint16
+	// Min and Max, if Min < Max, limit the register values written, taken
+	// as int16 if Signed and as uint16 otherwise.
+	Min, Max int
+	Signed   bool
+}
+
+// GuardPolicy configures a Guard.
+type GuardPolicy struct {
+	// Allow lists the ranges writes may go to; all others are denied.
+	Allow []GuardRange
+	// DryRun logs the encoded request of allowed writes, with direction
+	// DirectionDryRun, instead of sending it. It requires a client that
+	// logs at LogLevelInfo.
+	DryRun bool
+}
+
+// Guard is a Client that rejects writes outside an allowlist with
+// ErrWriteDenied. Reads pass through. Only the methods of Client are
+// guarded, writes made on the wrapped ClientHandler directly are not.
+type Guard struct {
+	client *ClientHandler
+	policy GuardPolicy
+}
+
+// NewGuard wraps client with policy.
+func NewGuard(client *ClientHandler, policy GuardPolicy) (*Guard, error) {
+	for _, r := range policy.Allow {
+		if r.Table != TableCoils && r.Table != TableHoldingRegisters {
+			return nil, fmt.Errorf("modbus: guard range of %v is not writable", r.Table)
+		}
+		if r.Start > r.End {
+			return nil, fmt.Errorf("modbus: guard range '%v' to '%v' is empty", r.Start, r.End)
+		}
+		if r.Min < r.Max && r.Table == TableCoils {
+			return nil, fmt.Errorf("modbus: guard limits do not apply to coils")
+		}
+	}
+	if policy.DryRun && !client.logs(LogLevelInfo) {
+		return nil, fmt.Errorf("modbus: a dry run requires a client with a logger or log handler")
+	}
+	policy.Allow = append([]GuardRange(nil), policy.Allow...)
+	return &Guard{client: client, policy: policy}, nil
+}
+
+func (g *Guard) slaveID() byte {
+	g.client.mu.Lock()
+	defer g.client.mu.Unlock()
+	return g.client.SlaveID
+}
+
+// check denies a write of quantity items of table at address of slaveID,
+// or of values if given, unless an allowed range covers it.
+func (g *Guard) check(slaveID, functionCode byte, table Table, address uint16, quantity int, values []uint16) error {
+	last := int(address) + quantity - 1
+	for _, r := range g.policy.Allow {
+		if r.SlaveID != slaveID || r.Table != table || address < r.Start || last > int(r.End) {
+			continue
+		}
+		if r.Min >= r.Max {
+			return nil
+		}
+		for i, v := range values {
+			value := int(v)
+			if r.Signed {
+				value = int(int16(v))
+			}
+			if value < r.Min || value > r.Max {
+				return denied(slaveID, functionCode, "modbus: value '%v' at address '%v' is out of range ['%v', '%v']", value, int(address)+i, r.Min, r.Max)
+			}
+		}
+		return nil
+	}
+	return denied(slaveID, functionCode, "modbus: write of %v %v to '%v' of slave '%v' is not allowed", quantity, table, address, slaveID)
+}
+
+func denied(slaveID, functionCode byte, format string, v ...interface{}) error {
+	e := errorf(ErrWriteDenied, format, v...)
+	e.SlaveID = slaveID
+	e.FunctionCode = functionCode
+	return e
+}
+
+// send sends r to slaveID, the slave it was checked for, even if the slave
+// of the client changed meanwhile. In a dry run it logs r instead.
+func (g *Guard) send(slaveID byte, r *request) result {
+	if g.policy.DryRun {
+		return result{err: g.dryRun(slaveID, r)}
+	}
+	return g.client.call(&slaveID, r)
+}
+
+// dryRun logs the encoded request instead of sending it.
+func (g *Guard) dryRun(slaveID byte, r *request) error {
+	if r.err != nil {
+		annotate(r.err, slaveID, r.pdu.FunctionCode)
+		return r.err
+	}
+	c := g.client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	adu, err := c.Packager.Encode(slaveID, &r.pdu)
+	if err != nil {
+		annotate(err, slaveID, r.pdu.FunctionCode)
+		return err
+	}
+	c.logFor(slaveID, LogLevelInfo, LogRecord{
+		Direction:    DirectionDryRun,
+		FunctionCode: r.pdu.FunctionCode,
+		Message:      "dry run, request not sent",
+	}, adu)
+	return nil
+}
+
+func (g *Guard) SetLogger(logger Logger) {
+	g.client.SetLogger(logger)
+}
+
+func (g *Guard) Close() error {
+	return g.client.Close()
+}
+
+func (g *Guard) SetSlaveID(slaveID byte) Client {
+	g.client.SetSlaveID(slaveID)
+	return g
+}
+
+func (g *Guard) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	return g.client.ReadDiscreteInputs(address, quantity)
+}
+
+func (g *Guard) ReadCoils(address, quantity uint16) ([]bool, error) {
+	return g.client.ReadCoils(address, quantity)
+}
+
+func (g *Guard) WriteSingleCoil(address uint16, coil bool) error {
+	slaveID := g.slaveID()
+	if err := g.check(slaveID, FuncCodeWriteSingleCoil, TableCoils, address, 1, nil); err != nil {
+		return err
+	}
+	return g.send(slaveID, writeSingleCoilRequest(address, coil)).err
+}
+
+func (g *Guard) WriteMultipleCoils(address uint16, coils []bool) error {
+	slaveID := g.slaveID()
+	if err := g.check(slaveID, FuncCodeWriteMultipleCoils, TableCoils, address, len(coils), nil); err != nil {
+		return err
+	}
+	return g.send(slaveID, writeMultipleCoilsRequest(address, coils)).err
+}
+
+func (g *Guard) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	return g.client.ReadHoldingRegisters(address, quantity)
+}
+
+func (g *Guard) ReadInputRegisters(address, quantity uint16) ([]uint16, error) {
+	return g.client.ReadInputRegisters(address, quantity)
+}
+
+func (g *Guard) WriteSingleRegister(address, value uint16) error {
+	slaveID := g.slaveID()
+	if err := g.check(slaveID, FuncCodeWriteSingleRegister, TableHoldingRegisters, address, 1, []uint16{value}); err != nil {
+		return err
+	}
+	return g.send(slaveID, writeSingleRegisterRequest(address, value)).err
+}
+
+func (g *Guard) WriteMultipleRegisters(address uint16, values []uint16) error {
+	slaveID := g.slaveID()
+	if err := g.check(slaveID, FuncCodeWriteMultipleRegisters, TableHoldingRegisters, address, len(values), values); err != nil {
+		return err
+	}
+	return g.send(slaveID, writeMultipleRegistersRequest(address, values)).err
+}
+
+// ReadWriteMultipleRegisters checks the registers written. In a dry run it
+// returns no registers.
+func (g *Guard) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]uint16, error) {
+	slaveID := g.slaveID()
+	if len(value) != 2*int(writeQuantity) {
+		e := requestErrorf(ErrInvalidQuantity, FuncCodeReadWriteMultipleRegisters, "modbus: length of value '%v' does not match quantity to write '%v'", len(value), writeQuantity)
+		e.SlaveID = slaveID
+		return nil, e
+	}
+	values := make([]uint16, writeQuantity)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(value[2*i:])
+	}
+	if err := g.check(slaveID, FuncCodeReadWriteMultipleRegisters, TableHoldingRegisters, writeAddress, int(writeQuantity), values); err != nil {
+		return nil, err
+	}
+	res := g.send(slaveID, readWriteMultipleRegistersRequest(readAddress, readQuantity, writeAddress, writeQuantity, value))
+	return res.registers, res.err
+}
+
+// MaskWriteRegister checks the address. If the range limits values, the
+// register is read instead, and the value it would take is checked and
+// written with WriteSingleRegister, as the other read-modify-writes of the
+// client are, so that bits changed meanwhile cannot take it out of range.
+func (g *Guard) MaskWriteRegister(address, andMask, orMask uint16) error {
+	return g.maskWrite(address, andMask, orMask, true)
+}
+
+// maskWrite is MaskWriteRegister, taking the read-modify-write lock of the
+// client if lock is set.
+func (g *Guard) maskWrite(address, andMask, orMask uint16, lock bool) error {
+	slaveID := g.slaveID()
+	if err := g.check(slaveID, FuncCodeMaskWriteRegister, TableHoldingRegisters, address, 1, nil); err != nil {
+		return err
+	}
+	if !g.limited(slaveID, address) {
+		return g.send(slaveID, maskWriteRegisterRequest(address, andMask, orMask)).err
+	}
+	if lock {
+		g.client.rmw.Lock()
+		defer g.client.rmw.Unlock()
+	}
+	res := g.client.call(&slaveID, readRegistersRequest(g.client.enron, FuncCodeReadHoldingRegisters, address, 1))
+	if res.err != nil {
+		return res.err
+	}
+	value := res.registers[0]&andMask | orMask&^andMask
+	if err := g.check(slaveID, FuncCodeMaskWriteRegister, TableHoldingRegisters, address, 1, []uint16{value}); err != nil {
+		return err
+	}
+	return g.send(slaveID, writeSingleRegisterRequest(address, value)).err
+}
+
+// limited reports whether the allowed range of a holding register of
+// slaveID limits its values.
+func (g *Guard) limited(slaveID byte, address uint16) bool {
+	for _, r := range g.policy.Allow {
+		if r.SlaveID == slaveID && r.Table == TableHoldingRegisters && address >= r.Start && address <= r.End {
+			return r.Min < r.Max
+		}
+	}
+	return false
+}
+
+func (g *Guard) ReadFIFOQueue(address uint16) ([]uint16, error) {
+	return g.client.ReadFIFOQueue(address)
+}
+
+func (g *Guard) DiscreteInput(address uint16) DiscreteInput {
+	return g.client.DiscreteInput(address)
+}
+
+func (g *Guard) Coil(address uint16) Coil {
+	return &rwBit{master: g, address: address, lock: &g.client.rmw}
+}
+
+func (g *Guard) InputRegister(address uint16) InputRegister {
+	return g.client.InputRegister(address)
+}
+
+func (g *Guard) InputRegisters(address, count uint16) InputRegisters {
+	return g.client.InputRegisters(address, count)
+}
+
+func (g *Guard) HoldingRegister(address uint16) HoldingRegister {
+	return &rwRegister{master: guardLocked{g}, address: address, lock: &g.client.rmw}
+}
+
+// guardLocked is the master of the holding register accessors of a guard,
+// whose bit and field writes already hold the read-modify-write lock of the
+// client.
+type guardLocked struct {
+	*Guard
+}
+
+func (g guardLocked) MaskWriteRegister(address, andMask, orMask uint16) error {
+	return g.maskWrite(address, andMask, orMask, false)
+}
+
+func (g *Guard) HoldingRegisters(address, count uint16) HoldingRegisters {
+	return &rwRegisters{master: g, address: address, count: count}
+}
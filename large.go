@@ -0,0 +1,201 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// Maximum quantities of a single request as defined by the Modbus
+// application protocol specification.
+const (
+	MaxReadBits       = 2000
+	MaxReadRegisters  = 125
+	MaxWriteCoils     = 1968
+	MaxWriteRegisters = 123
+	// MaxReadWriteRegisters is the quantity of registers written by
+	// ReadWriteMultipleRegisters.
+	MaxReadWriteRegisters = 121
+)
+
+// ChunkError reports the failed request of a *Large method. Chunks before
+// Index completed, writes of later chunks may or may not have been applied
+// when requests were pipelined. Pipelined writes that failed are not
+// retried.
+type ChunkError struct {
+	// Index of the chunk, counting from zero.
+	Index    int
+	Address  uint16
+	Quantity uint16
+	Err      error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("modbus: chunk %d (address '%v', quantity '%v'): %v", e.Index, e.Address, e.Quantity, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// chunk is one request of a split range.
+type chunk struct {
+	address  uint16
+	quantity uint16
+	request  *request
+}
+
+// ReadCoilsLarge reads any number of coils, split into requests of at most
+// MaxReadBits. The requests are pipelined if enabled with WithPipelining.
+func (c *ClientHandler) ReadCoilsLarge(address, quantity uint16) (coils []bool, err error) {
+	return c.readBitsLarge(FuncCodeReadCoils, address, quantity)
+}
+
+// ReadDiscreteInputsLarge reads any number of discrete inputs, split into
+// requests of at most MaxReadBits.
+func (c *ClientHandler) ReadDiscreteInputsLarge(address, quantity uint16) (inputs []bool, err error) {
+	return c.readBitsLarge(FuncCodeReadDiscreteInputs, address, quantity)
+}
+
+// ReadHoldingRegistersLarge reads any number of holding registers, split
+// into requests of at most MaxReadRegisters. In Enron ranges requests stop
+// at the range boundaries and read at most MaxReadEnron registers, each
+// returned as two words as by ReadHoldingRegisters.
+func (c *ClientHandler) ReadHoldingRegistersLarge(address, quantity uint16) (values []uint16, err error) {
+	return c.readRegistersLarge(FuncCodeReadHoldingRegisters, address, quantity)
+}
+
+// ReadInputRegistersLarge reads any number of input registers, split into
+// requests as by ReadHoldingRegistersLarge.
+func (c *ClientHandler) ReadInputRegistersLarge(address, quantity uint16) (values []uint16, err error) {
+	return c.readRegistersLarge(FuncCodeReadInputRegisters, address, quantity)
+}
+
+// WriteMultipleCoilsLarge writes any number of coils, split into requests
+// of at most MaxWriteCoils. The write is not atomic: on error, a
+// *ChunkError tells which part failed.
+func (c *ClientHandler) WriteMultipleCoilsLarge(address uint16, coils []bool) (err error) {
+	chunks, err := c.split(FuncCodeWriteMultipleCoils, address, len(coils), MaxWriteCoils, nil)
+	if err != nil {
+		return
+	}
+	offset := 0
+	for _, ch := range chunks {
+		ch.request = writeMultipleCoilsRequest(ch.address, coils[offset:offset+int(ch.quantity)])
+		offset += int(ch.quantity)
+	}
+	_, err = c.runChunks(chunks)
+	return
+}
+
+// WriteMultipleRegistersLarge writes any number of holding registers, split
+// into requests of at most MaxWriteRegisters. The write is not atomic: on
+// error, a *ChunkError tells which part failed.
+func (c *ClientHandler) WriteMultipleRegistersLarge(address uint16, values []uint16) (err error) {
+	chunks, err := c.split(FuncCodeWriteMultipleRegisters, address, len(values), MaxWriteRegisters, nil)
+	if err != nil {
+		return
+	}
+	offset := 0
+	for _, ch := range chunks {
+		ch.request = writeMultipleRegistersRequest(ch.address, values[offset:offset+int(ch.quantity)])
+		offset += int(ch.quantity)
+	}
+	_, err = c.runChunks(chunks)
+	return
+}
+
+func (c *ClientHandler) readBitsLarge(functionCode byte, address, quantity uint16) (bits []bool, err error) {
+	chunks, err := c.split(functionCode, address, int(quantity), MaxReadBits, nil)
+	if err != nil {
+		return
+	}
+	for _, ch := range chunks {
+		ch.request = readBitsRequest(functionCode, ch.address, ch.quantity)
+	}
+	results, err := c.runChunks(chunks)
+	if err != nil {
+		return
+	}
+	bits = make([]bool, 0, quantity)
+	for _, res := range results {
+		bits = append(bits, res.bits...)
+	}
+	return
+}
+
+func (c *ClientHandler) readRegistersLarge(functionCode byte, address, quantity uint16) (values []uint16, err error) {
+	chunks, err := c.split(functionCode, address, int(quantity), MaxReadRegisters, c.enron)
+	if err != nil {
+		return
+	}
+	for _, ch := range chunks {
+		ch.request = readRegistersRequest(c.enron, functionCode, ch.address, ch.quantity)
+	}
+	results, err := c.runChunks(chunks)
+	if err != nil {
+		return
+	}
+	values = make([]uint16, 0, quantity)
+	for _, res := range results {
+		values = append(values, res.registers...)
+	}
+	return
+}
+
+// split divides quantity items starting at address into chunks of at most
+// max items. Chunks of registers do not mix the widths of enron, see
+// enronRanges.span. Their requests are left to the caller.
+func (c *ClientHandler) split(functionCode byte, address uint16, quantity, max int, enron enronRanges) (chunks []*chunk, err error) {
+	if quantity < 1 || int(address)+quantity > 65536 {
+		err = c.errorf(ErrInvalidQuantity, functionCode, "modbus: quantity '%v' at address '%v' must be between '%v' and '%v'", quantity, address, 1, 65536-int(address))
+		return
+	}
+	for offset := 0; offset < quantity; {
+		at := address + uint16(offset)
+		n := enron.span(at, quantity-offset, max)
+		chunks = append(chunks, &chunk{address: at, quantity: uint16(n)})
+		offset += n
+	}
+	return
+}
+
+// runChunks sends the requests of chunks in order, pipelined if enabled,
+// and returns their results. It stops at the first failure, except that
+// all pipelined requests are sent before any failure is seen. Reads that
+// failed in transport are then retried one at a time, writes are not, as
+// later chunks may have been applied already.
+func (c *ClientHandler) runChunks(chunks []*chunk) (results []result, err error) {
+	chunkError := func(i int, err error) error {
+		return &ChunkError{Index: i, Address: chunks[i].address, Quantity: chunks[i].quantity, Err: err}
+	}
+	results = make([]result, len(chunks))
+	if depth, retry := c.pipelineDepth(); depth > 1 && len(chunks) > 1 {
+		requests := make([]*request, len(chunks))
+		for i, ch := range chunks {
+			requests[i] = ch.request
+		}
+		batch, slaveID := c.roundTripBatch(nil, requests, depth)
+		for i, ch := range chunks {
+			res := batch[i]
+			if _, ok := res.err.(*ModbusError); res.err != nil && !ok && retry && !ch.request.writes() {
+				// Retry failed transfers one at a time
+				res, _ = c.roundTrip(&slaveID, ch.request)
+			}
+			if res.err != nil {
+				return nil, chunkError(i, res.err)
+			}
+			results[i] = res
+		}
+		return
+	}
+	// All chunks go to the slave of the first
+	var to *byte
+	for i, ch := range chunks {
+		res, slaveID := c.roundTrip(to, ch.request)
+		if res.err != nil {
+			return nil, chunkError(i, res.err)
+		}
+		results[i] = res
+		to = &slaveID
+	}
+	return
+}
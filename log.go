@@ -0,0 +1,139 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// LogLevel is the severity of a log record.
+type LogLevel int
+
+const (
+	// LogLevelDebug is used for every frame sent and received.
+	LogLevelDebug LogLevel = iota
+	// LogLevelInfo is used for retries and reconnects.
+	LogLevelInfo
+	// LogLevelError is used for failed requests.
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Directions of a frame in a log record.
+const (
+	DirectionSend    = "send"
+	DirectionReceive = "receive"
+	// DirectionDryRun is a request encoded by a dry-run Guard but not sent.
+	// Its frame is always included.
+	DirectionDryRun = "dry run"
+)
+
+// LogRecord describes one event of a client handler.
+type LogRecord struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+
+	// Direction is DirectionSend or DirectionReceive for frame events.
+	Direction     string
+	SlaveID       byte
+	FunctionCode  byte
+	TransactionID uint16 // Modbus TCP only
+	// Bytes is the size of the frame or zero.
+	Bytes int
+	// Frame is the raw ADU, set only when frame logging is enabled.
+	Frame []byte
+	// Duration is the time since the request was sent.
+	Duration time.Duration
+	Attempt  int
+	Err      error
+}
+
+// LogHandler receives structured log records from a client handler.
+type LogHandler interface {
+	// Enabled reports whether records of the given level are handled, so
+	// that the client can avoid building them.
+	Enabled(level LogLevel) bool
+	Handle(r *LogRecord)
+}
+
+// outputLogHandler adapts a Logger, keeping its historical behaviour of
+// dumping every frame and nothing else.
+type outputLogHandler struct {
+	logger Logger
+}
+
+func (h outputLogHandler) Enabled(level LogLevel) bool {
+	return level <= LogLevelInfo
+}
+
+func (h outputLogHandler) Handle(r *LogRecord) {
+	switch {
+	case r.Frame == nil:
+	case r.Direction == DirectionSend:
+		h.logger.Output(2, fmt.Sprintf("modbus: sending % x", r.Frame))
+	case r.Direction == DirectionReceive:
+		h.logger.Output(2, fmt.Sprintf("modbus: received % x", r.Frame))
+	case r.Direction == DirectionDryRun:
+		h.logger.Output(2, fmt.Sprintf("modbus: dry run, not sending % x", r.Frame))
+	}
+}
+
+// logHandler returns the handler records are sent to, if any, and whether
+// frames are included. The caller must hold c.mu.
+func (c *ClientHandler) logHandler() (h LogHandler, frames bool) {
+	if c.handler != nil {
+		return c.handler, c.logFrames
+	}
+	if c.Logger != nil {
+		return outputLogHandler{c.Logger}, true
+	}
+	return nil, false
+}
+
+// logs reports whether records of level are logged.
+func (c *ClientHandler) logs(level LogLevel) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, _ := c.logHandler()
+	return h != nil && h.Enabled(level)
+}
+
+// log emits a record built from the request ADU if level is enabled.
+// The caller must hold c.mu.
+func (c *ClientHandler) log(level LogLevel, r LogRecord, adu []byte) {
+	c.logFor(c.SlaveID, level, r, adu)
+}
+
+// logFor is log for a request to slaveID, which may differ from the slave
+// of c. The caller must hold c.mu.
+func (c *ClientHandler) logFor(slaveID byte, level LogLevel, r LogRecord, adu []byte) {
+	h, frames := c.logHandler()
+	if h == nil || !h.Enabled(level) {
+		return
+	}
+	r.Time = time.Now()
+	r.Level = level
+	r.SlaveID = slaveID
+	if _, ok := c.Packager.(*TCPPackager); ok && len(adu) >= tcpHeaderSize {
+		r.TransactionID = binary.BigEndian.Uint16(adu)
+	}
+	if r.Direction != "" {
+		r.Bytes = len(adu)
+		if frames || r.Direction == DirectionDryRun {
+			r.Frame = adu
+		}
+	}
+	h.Handle(&r)
+}
@@ -0,0 +1,103 @@
+package modbus
+
+import (
+	"time"
+)
+
+// MetricLabels identify the device and function a measurement belongs to.
+type MetricLabels struct {
+	// Transport is one of tcp, rtu, ascii, rtuovertcp or asciiovertcp.
+	Transport string
+	// Endpoint is the TCP address or serial device.
+	Endpoint     string
+	SlaveID      byte
+	FunctionCode byte
+}
+
+// Metrics receives measurements from a client handler. Implementations
+// must be safe for concurrent use when shared between handlers.
+type Metrics interface {
+	// ObserveRequest is called once per request, after all retries, with
+	// its latency. err is nil on success.
+	ObserveRequest(labels MetricLabels, latency time.Duration, err error)
+	// ObserveException is called for every exception response.
+	ObserveException(labels MetricLabels, exceptionCode byte)
+	// ObserveTimeout is called for every attempt that timed out.
+	ObserveTimeout(labels MetricLabels)
+	// ObserveChecksumError is called for every response failing the
+	// CRC or LRC check.
+	ObserveChecksumError(labels MetricLabels)
+	// ObserveReconnect is called whenever the connection is dropped to be
+	// re-established.
+	ObserveReconnect(labels MetricLabels)
+}
+
+// QueueMetrics is implemented by Metrics that also measure the request
+// queue of clients, see WithPriority and WithMaxWait.
+type QueueMetrics interface {
+	// ObserveQueue is called once per request taken from the queue, with
+	// the time it waited and the number of requests it found waiting. err
+	// is an ErrDeadlineExceeded error if the request was dropped.
+	ObserveQueue(labels MetricLabels, wait time.Duration, depth int, err error)
+}
+
+// metricLabels returns the labels of a request with the given function
+// code. The caller must hold c.mu.
+func (c *ClientHandler) metricLabels(functionCode byte) MetricLabels {
+	return MetricLabels{
+		Transport:    transportName(c.Packager, c.Transporter),
+		Endpoint:     endpoint(c.Transporter),
+		SlaveID:      c.SlaveID,
+		FunctionCode: functionCode,
+	}
+}
+
+// transportName names the packager and transporter combination the way
+// Dial's url schemes do.
+func transportName(packager Packager, transporter Transporter) string {
+	var overTCP bool
+	switch transporter.(type) {
+	case *tcpAddrCategoryPort, *tcpConnCategoryPort, *pooledTransport:
+		overTCP = true
+	}
+	var name string
+	switch packager.(type) {
+	case *TCPPackager:
+		return "tcp"
+	case *RTUPackager:
+		name = "rtu"
+	case *ASCIIPackager:
+		name = "ascii"
+	default:
+		return "unknown"
+	}
+	if overTCP {
+		name += "overtcp"
+	}
+	return name
+}
+
+// endpoint returns the address or device of a transporter.
+func endpoint(transporter Transporter) string {
+	switch t := transporter.(type) {
+	case *tcpAddrCategoryPort:
+		return t.address
+	case *pooledTransport:
+		return t.address
+	case *tcpConnCategoryPort:
+		if t.conn != nil {
+			return t.conn.RemoteAddr().String()
+		}
+	case *serialPort:
+		return t.Device
+	}
+	return ""
+}
+
+// isTimeout reports whether err is a timeout of the transporter.
+func isTimeout(err error) bool {
+	t, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && t.Timeout()
+}
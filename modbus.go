@@ -79,5 +79,5 @@ type Packager interface {
 	Encode(slaveID byte, pdu *ProtocolDataUnit) (adu []byte, err error)
 	Decode(adu []byte) (pdu *ProtocolDataUnit, err error)
 	Verify(aduRequest []byte, aduResponse []byte) (err error)
-	transceive(transporter Transporter, logger Logger, aduRequest []byte, timeout time.Duration) (aduResponse []byte, err error)
+	transceive(transporter Transporter, aduRequest []byte, timeout time.Duration, enron enronRanges) (aduResponse []byte, err error)
 }
@@ -0,0 +1,246 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Option configures a ClientHandler.
+type Option func(c *ClientHandler) error
+
+// WithTimeout sets the response timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *ClientHandler) error {
+		if timeout < 0 {
+			return fmt.Errorf("modbus: timeout '%v' must not be negative", timeout)
+		}
+		c.Timeout = timeout
+		return nil
+	}
+}
+
+// WithUnitID sets the slave (unit) id requests are addressed to.
+func WithUnitID(id byte) Option {
+	return func(c *ClientHandler) error {
+		c.SlaveID = id
+		return nil
+	}
+}
+
+// WithLogger sets the logger frames are dumped to.
+func WithLogger(logger Logger) Option {
+	return func(c *ClientHandler) error {
+		c.Logger = logger
+		return nil
+	}
+}
+
+// WithLogHandler sets the handler structured log records are sent to.
+func WithLogHandler(h LogHandler) Option {
+	return func(c *ClientHandler) error {
+		c.handler = h
+		return nil
+	}
+}
+
+// WithFrameLogging includes the raw frames in the debug records sent to the
+// log handler. Frames are off by default to keep production logs quiet.
+func WithFrameLogging(enable bool) Option {
+	return func(c *ClientHandler) error {
+		c.logFrames = enable
+		return nil
+	}
+}
+
+// WithMetrics sets the receiver of request measurements, such as a
+// PrometheusMetrics shared by all clients of a process.
+func WithMetrics(m Metrics) Option {
+	return func(c *ClientHandler) error {
+		c.metrics = m
+		return nil
+	}
+}
+
+// WithRetry retries a request up to retries times, waiting backoff between
+// attempts, when it fails in transport, framing or checksum. Modbus
+// exceptions are never retried.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *ClientHandler) error {
+		if retries < 0 {
+			return fmt.Errorf("modbus: retries '%v' must not be negative", retries)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("modbus: retry backoff '%v' must not be negative", backoff)
+		}
+		c.retries = retries
+		c.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithReconnect closes the transporter after a failed exchange so that the
+// next attempt establishes a fresh connection or reopens the serial port.
+func WithReconnect(reconnect bool) Option {
+	return func(c *ClientHandler) error {
+		c.reconnect = reconnect
+		return nil
+	}
+}
+
+// WithInterFrameDelay sets the minimum quiet time between the end of a
+// response and the next request, as needed by slow serial devices. It is
+// the Gap of WithSerialTiming.
+func WithInterFrameDelay(delay time.Duration) Option {
+	return func(c *ClientHandler) error {
+		if delay < 0 {
+			return fmt.Errorf("modbus: inter-frame delay '%v' must not be negative", delay)
+		}
+		c.timing.Gap = delay
+		return nil
+	}
+}
+
+// WithConnectTimeout sets the timeout for establishing a TCP connection.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *ClientHandler) error {
+		if timeout <= 0 {
+			return fmt.Errorf("modbus: connect timeout '%v' must be positive", timeout)
+		}
+		c.connectTimeout = timeout
+		return nil
+	}
+}
+
+// WithTCPPool takes the connections of a TCP address transport from pool,
+// holding one for each request only, see TCPPool.
+func WithTCPPool(pool *TCPPool) Option {
+	return func(c *ClientHandler) error {
+		if pool == nil {
+			return fmt.Errorf("modbus: connection pool must not be nil")
+		}
+		c.pool = pool
+		return nil
+	}
+}
+
+// WithPipelining lets the *Large methods keep up to depth requests in flight
+// on a Modbus TCP connection, matching responses by transaction id. Many
+// devices serve one request at a time, so it is off by default.
+func WithPipelining(depth int) Option {
+	return func(c *ClientHandler) error {
+		if depth < 1 {
+			return fmt.Errorf("modbus: pipelining depth '%v' must be positive", depth)
+		}
+		c.pipeline = depth
+		return nil
+	}
+}
+
+// WithEnronRanges reads the given ordered address ranges as 32-bit
+// registers of the Enron (Daniel) extension: ReadHoldingRegisters and
+// ReadInputRegisters expect four bytes per register there and return two
+// words per register. On Modbus RTU the ranges are set on the packager,
+// which must not be shared with other clients.
+func WithEnronRanges(ranges ...EnronRange) Option {
+	return func(c *ClientHandler) error {
+		if err := validEnronRanges(ranges); err != nil {
+			return err
+		}
+		c.enron = append(enronRanges(nil), ranges...)
+		return nil
+	}
+}
+
+// WithZeroBasedRefs numbers the registers of Modicon notations from 0, for
+// devices whose register maps start at 40000, see ParseRef.
+func WithZeroBasedRefs(zeroBased bool) Option {
+	return func(c *ClientHandler) error {
+		c.zeroBased = zeroBased
+		return nil
+	}
+}
+
+// WithWriteVerify reads back the coils and holding registers written with
+// function codes 5, 6, 15 and 16, and fails writes that differ with a
+// *WriteVerifyError. It doubles the requests of writes.
+func WithWriteVerify(v WriteVerify) Option {
+	return func(c *ClientHandler) error {
+		if v.Tolerance < 0 || math.IsNaN(v.Tolerance) {
+			return fmt.Errorf("modbus: write verify tolerance '%v' must not be negative", v.Tolerance)
+		}
+		c.verify = &writeVerify{WriteVerify: v, floats: make(map[uint16]bool)}
+		for _, address := range v.Float32 {
+			c.verify.floats[address] = true
+		}
+		return nil
+	}
+}
+
+// WithAudit records every write request sent, with function code 5, 6, 15,
+// 16, 22 or 23, to a.Sink, e.g. a JSONLinesSink.
+func WithAudit(a Audit) Option {
+	return func(c *ClientHandler) error {
+		if a.Sink == nil {
+			return fmt.Errorf("modbus: audit needs a sink")
+		}
+		c.audit = &a
+		return nil
+	}
+}
+
+// WithSerialTiming sets the delays between frames on a serial bus. Requests
+// to slave 0 are broadcast and not answered; only writes other than function
+// code 23 may be broadcast, others fail with ErrBroadcast.
+func WithSerialTiming(t SerialTiming) Option {
+	return func(c *ClientHandler) error {
+		if err := t.valid(); err != nil {
+			return err
+		}
+		c.timing = t
+		return nil
+	}
+}
+
+// WithSlaveTiming sets the delays after requests to slaveID, replacing those
+// of WithSerialTiming.
+func WithSlaveTiming(slaveID byte, t SerialTiming) Option {
+	return func(c *ClientHandler) error {
+		if err := t.valid(); err != nil {
+			return err
+		}
+		if c.slaveTiming == nil {
+			c.slaveTiming = make(map[byte]SerialTiming)
+		}
+		c.slaveTiming[slaveID] = t
+		return nil
+	}
+}
+
+// WithPriority serves the requests of the client one at a time in a queue,
+// before those of clients of lower priority sharing the queue, see Bus.
+func WithPriority(priority int) Option {
+	return func(c *ClientHandler) error {
+		c.priority = priority
+		if c.queue == nil {
+			c.queue = &requestQueue{}
+		}
+		return nil
+	}
+}
+
+// WithMaxWait serves the requests of the client one at a time in a queue,
+// dropping those that wait longer than maxWait for their turn with
+// ErrDeadlineExceeded. Within a priority, earlier deadlines go first.
+func WithMaxWait(maxWait time.Duration) Option {
+	return func(c *ClientHandler) error {
+		if maxWait <= 0 {
+			return fmt.Errorf("modbus: maximum queue wait '%v' must be positive", maxWait)
+		}
+		c.maxWait = maxWait
+		if c.queue == nil {
+			c.queue = &requestQueue{}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,278 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// BCDCodec returns the codec of unsigned integers of 4 decimal digits per
+// register, 1 to 4 registers laid out in order. Decode returns a uint64.
+func BCDCodec(words int, order WordOrder) Codec {
+	return bcdCodec{words, order}
+}
+
+type bcdCodec struct {
+	words int
+	order WordOrder
+}
+
+func (c bcdCodec) Words() int {
+	return c.words
+}
+
+func (c bcdCodec) valid() error {
+	if c.words < 1 || c.words > 4 {
+		return fmt.Errorf("modbus: BCD of %v registers is not supported", c.words)
+	}
+	return nil
+}
+
+func (c bcdCodec) Decode(words []uint16) (v interface{}, err error) {
+	if err = c.valid(); err != nil {
+		return
+	}
+	if len(words) != c.words {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as BCD of %v", len(words), c.words)
+		return
+	}
+	var u uint64
+	for _, b := range c.order.bytes(words) {
+		hi, lo := b>>4, b&0x0F
+		if hi > 9 || lo > 9 {
+			err = fmt.Errorf("modbus: byte '%#02x' is not BCD", b)
+			return
+		}
+		u = u*100 + uint64(hi)*10 + uint64(lo)
+	}
+	return u, nil
+}
+
+func (c bcdCodec) Encode(v interface{}) (words []uint16, err error) {
+	if err = c.valid(); err != nil {
+		return
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as BCD", v)
+		return
+	}
+	u, ok := n.uint(64)
+	max := uint64(1)
+	for i := 0; i < c.words; i++ {
+		max *= 10000
+	}
+	if !ok || u >= max {
+		err = fmt.Errorf("modbus: value '%v' overflows BCD of %v registers", v, c.words)
+		return
+	}
+	b := make([]byte, 2*c.words)
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(u%10) | byte(u/10%10)<<4
+		u /= 100
+	}
+	return c.order.words(b), nil
+}
+
+// Counter48Codec returns the codec of unsigned 48-bit counters in three
+// registers laid out in order; OrderCDAB puts the low register first.
+// Decode returns a uint64.
+func Counter48Codec(order WordOrder) Codec {
+	return counter48Codec{order}
+}
+
+type counter48Codec struct {
+	order WordOrder
+}
+
+func (c counter48Codec) Words() int {
+	return 3
+}
+
+func (c counter48Codec) Decode(words []uint16) (v interface{}, err error) {
+	if len(words) != 3 {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as 48-bit counter", len(words))
+		return
+	}
+	var u uint64
+	for _, b := range c.order.bytes(words) {
+		u = u<<8 | uint64(b)
+	}
+	return u, nil
+}
+
+func (c counter48Codec) Encode(v interface{}) (words []uint16, err error) {
+	n, ok := toNumber(v)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as 48-bit counter", v)
+		return
+	}
+	u, ok := n.uint(48)
+	if !ok {
+		err = fmt.Errorf("modbus: value '%v' overflows 48-bit counter", v)
+		return
+	}
+	b := make([]byte, 6)
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(u)
+		u >>= 8
+	}
+	return c.order.words(b), nil
+}
+
+// UnixTimeCodec returns the codec of seconds since 1970-01-01 UTC, unsigned
+// in two registers laid out in order. Decode returns a time.Time in UTC,
+// Encode accepts a time.Time.
+func UnixTimeCodec(order WordOrder) Codec {
+	return unixTimeCodec{order}
+}
+
+type unixTimeCodec struct {
+	order WordOrder
+}
+
+func (c unixTimeCodec) Words() int {
+	return 2
+}
+
+func (c unixTimeCodec) Decode(words []uint16) (v interface{}, err error) {
+	if len(words) != 2 {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as Unix time", len(words))
+		return
+	}
+	sec := binary.BigEndian.Uint32(c.order.bytes(words))
+	return time.Unix(int64(sec), 0).UTC(), nil
+}
+
+func (c unixTimeCodec) Encode(v interface{}) (words []uint16, err error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as Unix time", v)
+		return
+	}
+	sec := t.Unix()
+	if sec < 0 || sec > 0xFFFFFFFF {
+		err = fmt.Errorf("modbus: time '%v' is out of range of Unix time", t)
+		return
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(sec))
+	return c.order.words(b), nil
+}
+
+// DateTimeCodec returns the codec of a time stored as six registers: year,
+// month, day, hour, minute and second, in loc or UTC if nil. Decode returns
+// a time.Time, Encode accepts a time.Time.
+func DateTimeCodec(loc *time.Location) Codec {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return dateTimeCodec{loc}
+}
+
+type dateTimeCodec struct {
+	loc *time.Location
+}
+
+func (c dateTimeCodec) Words() int {
+	return 6
+}
+
+func (c dateTimeCodec) Decode(words []uint16) (v interface{}, err error) {
+	if len(words) != 6 {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as date and time", len(words))
+		return
+	}
+	return civilTime(int(words[0]), int(words[1]), int(words[2]), int(words[3]), int(words[4]), int(words[5]), 0, c.loc)
+}
+
+func (c dateTimeCodec) Encode(v interface{}) (words []uint16, err error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as date and time", v)
+		return
+	}
+	t = t.In(c.loc)
+	if t.Year() < 0 || t.Year() > 0xFFFF {
+		err = fmt.Errorf("modbus: time '%v' is out of range", t)
+		return
+	}
+	return []uint16{uint16(t.Year()), uint16(t.Month()), uint16(t.Day()),
+		uint16(t.Hour()), uint16(t.Minute()), uint16(t.Second())}, nil
+}
+
+// CP56Time2aCodec returns the codec of the seven byte IEC 60870-5 time
+// stamp in four registers, the bytes in transmission order laid out in
+// order: OrderABCD puts the first byte in the high byte of the first
+// register, OrderBADC in its low byte. Years are 2000 to 2099 in loc, UTC
+// if nil. Decode returns a time.Time and fails for time stamps marked
+// invalid; Encode accepts a time.Time and leaves the summer time bit clear.
+func CP56Time2aCodec(order WordOrder, loc *time.Location) Codec {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return cp56Time2aCodec{order, loc}
+}
+
+type cp56Time2aCodec struct {
+	order WordOrder
+	loc   *time.Location
+}
+
+func (c cp56Time2aCodec) Words() int {
+	return 4
+}
+
+func (c cp56Time2aCodec) Decode(words []uint16) (v interface{}, err error) {
+	if len(words) != 4 {
+		err = fmt.Errorf("modbus: %v registers cannot be decoded as CP56Time2a", len(words))
+		return
+	}
+	b := c.order.bytes(words)
+	if b[2]&0x80 != 0 {
+		err = fmt.Errorf("modbus: CP56Time2a time stamp is marked invalid")
+		return
+	}
+	ms := int(binary.LittleEndian.Uint16(b))
+	if ms >= 60000 {
+		err = fmt.Errorf("modbus: CP56Time2a milliseconds '%v' are out of range", ms)
+		return
+	}
+	return civilTime(2000+int(b[6]&0x7F), int(b[5]&0x0F), int(b[4]&0x1F), int(b[3]&0x1F), int(b[2]&0x3F),
+		ms/1000, ms%1000*int(time.Millisecond), c.loc)
+}
+
+func (c cp56Time2aCodec) Encode(v interface{}) (words []uint16, err error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		err = fmt.Errorf("modbus: %T cannot be encoded as CP56Time2a", v)
+		return
+	}
+	t = t.In(c.loc)
+	if t.Year() < 2000 || t.Year() > 2099 {
+		err = fmt.Errorf("modbus: time '%v' is out of range of CP56Time2a", t)
+		return
+	}
+	// Monday is 1, Sunday 7
+	weekday := (int(t.Weekday())+6)%7 + 1
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint16(b, uint16(t.Second()*1000+t.Nanosecond()/int(time.Millisecond)))
+	b[2] = byte(t.Minute())
+	b[3] = byte(t.Hour())
+	b[4] = byte(t.Day()) | byte(weekday)<<5
+	b[5] = byte(t.Month())
+	b[6] = byte(t.Year() - 2000)
+	return c.order.words(b), nil
+}
+
+// civilTime returns the given time in loc, failing for fields out of range
+// such as February 30.
+func civilTime(year, month, day, hour, min, sec, nsec int, loc *time.Location) (t time.Time, err error) {
+	t = time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day ||
+		t.Hour() != hour || t.Minute() != min || t.Second() != sec {
+		err = fmt.Errorf("modbus: invalid date and time %04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, min, sec)
+		return time.Time{}, err
+	}
+	return
+}
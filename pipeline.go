@@ -0,0 +1,170 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// pipelineDepth returns the number of requests that may be in flight on the
+// connection, zero if pipelining is off or not possible, and whether failed
+// requests are to be retried.
+func (c *ClientHandler) pipelineDepth() (depth int, retry bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.Packager.(*TCPPackager); ok && c.pipeline > 1 {
+		depth = c.pipeline
+	}
+	return depth, c.retries > 0
+}
+
+// roundTripBatch sends requests to the slave of c, or to *to if not nil, with
+// up to depth of them in flight, see transceiveBatch, and decodes the
+// responses. It returns the slave the requests went to.
+func (c *ClientHandler) roundTripBatch(to *byte, requests []*request, depth int) (results []result, slaveID byte) {
+	results = make([]result, len(requests))
+	var sent []int
+	var valid []*request
+	for i, r := range requests {
+		if r.err == nil {
+			sent = append(sent, i)
+			valid = append(valid, r)
+		}
+	}
+	if len(valid) > 0 {
+		var batch []result
+		batch, slaveID = c.transceiveBatch(to, valid, depth)
+		for j, i := range sent {
+			results[i] = batch[j]
+		}
+	} else {
+		slaveID = c.slave(to)
+	}
+	for i, r := range requests {
+		if r.err != nil {
+			annotate(r.err, slaveID, r.pdu.FunctionCode)
+			results[i].err = r.err
+		}
+	}
+	return
+}
+
+// transceiveBatch sends requests to the slave of c, or to *to if not nil,
+// with up to depth of them in flight and returns the slave and the results
+// by index. Only Modbus TCP frames carry the transaction id needed to match
+// responses to requests, so c.Packager must be a TCPPackager. Failed
+// requests are not retried.
+func (c *ClientHandler) transceiveBatch(to *byte, requests []*request, depth int) (results []result, slaveID byte) {
+	n := len(requests)
+	results = make([]result, n)
+	completed := make([]bool, n)
+	aduRequests := make([][]byte, n)
+	starts := make([]time.Time, n)
+	audits := make([]*AuditRecord, n)
+
+	// The batch takes one turn in the queue of c
+	release, err := c.turn(requests[0].pdu.FunctionCode)
+	if err != nil {
+		slaveID = c.slave(to)
+		annotate(err, slaveID, requests[0].pdu.FunctionCode)
+		for i := range results {
+			results[i].err = err
+		}
+		return
+	}
+	defer release()
+	var failed error
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if to != nil {
+		defer func(saved byte) {
+			c.SlaveID = saved
+		}(c.SlaveID)
+		c.SlaveID = *to
+	}
+	slaveID = c.SlaveID
+	defer func() {
+		c.done(failed)
+	}()
+	tcp := c.Packager.(*TCPPackager)
+
+	// Transaction id to request index of the requests in flight
+	pending := make(map[uint16]int, depth)
+	next := 0
+	failed = c.Transporter.Connect()
+	for failed == nil && (next < n || len(pending) > 0) {
+		for next < n && len(pending) < depth {
+			i := next
+			next++
+			request := &requests[i].pdu
+			audits[i] = c.auditStart(request, false)
+			adu, err := tcp.Encode(c.SlaveID, request)
+			if err != nil {
+				c.annotate(err, request.FunctionCode)
+				results[i].err, completed[i] = err, true
+				continue
+			}
+			aduRequests[i] = adu
+			c.logSend(LogRecord{FunctionCode: request.FunctionCode}, adu)
+			starts[i] = time.Now()
+			if _, failed = c.Transporter.Write(adu); failed != nil {
+				break
+			}
+			pending[binary.BigEndian.Uint16(adu)] = i
+		}
+		if failed != nil || len(pending) == 0 {
+			continue
+		}
+		if c.Timeout > 0 {
+			if failed = c.Transporter.SetReadTimeout(c.Timeout); failed != nil {
+				break
+			}
+		}
+		var aduResponse []byte
+		if aduResponse, failed = tcp.readADU(c.Transporter); failed != nil {
+			break
+		}
+		i, ok := pending[binary.BigEndian.Uint16(aduResponse)]
+		if !ok {
+			// Late response to an earlier request that timed out
+			continue
+		}
+		delete(pending, binary.BigEndian.Uint16(aduResponse))
+		functionCode := requests[i].pdu.FunctionCode
+		response, err := c.receive(LogRecord{FunctionCode: functionCode}, aduRequests[i], aduResponse, nil, starts[i])
+		results[i], completed[i] = c.complete(requests[i], aduRequests[i], response, err, starts[i]), true
+	}
+
+	if failed != nil {
+		// Requests in flight or not sent yet share the failure.
+		var reported bool
+		for i, r := range requests {
+			if completed[i] {
+				continue
+			}
+			if aduRequests[i] == nil {
+				results[i].err = transportError(failed)
+				c.annotate(results[i].err, r.pdu.FunctionCode)
+				continue
+			}
+			err := failed
+			if !reported {
+				// Wraps timeouts, counts and reconnects once for the batch
+				_, err = c.receive(LogRecord{FunctionCode: r.pdu.FunctionCode}, aduRequests[i], nil, failed, starts[i])
+				failed = err
+				reported = true
+			}
+			results[i] = c.complete(r, aduRequests[i], nil, err, starts[i])
+		}
+		if !c.reconnect {
+			// Discard responses still on their way
+			c.Transporter.Flush()
+		}
+	}
+	for i, r := range requests {
+		if results[i].err == nil {
+			results[i].err = c.verifyWrite(r)
+		}
+		c.auditEnd(audits[i], results[i].err)
+	}
+	return
+}
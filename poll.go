@@ -0,0 +1,273 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Point is a value polled by a Poller.
+type Point struct {
+	// Name identifies the point to the sample handler.
+	Name    string
+	SlaveID byte
+	Table   Table
+	Address uint16
+	// Quantity of bits or registers, 1 if zero.
+	Quantity uint16
+	// Rate is the polling period.
+	Rate time.Duration
+}
+
+// Sample is the outcome of polling one point.
+type Sample struct {
+	Point Point
+	// Time the read completed.
+	Time time.Time
+	// Bits or Registers hold the values, depending on the table.
+	Bits      []bool
+	Registers []uint16
+	Err       error
+}
+
+// DeadlineMiss reports a poll that ended after the next one was due.
+type DeadlineMiss struct {
+	SlaveID byte
+	Table   Table
+	Rate    time.Duration
+	// Scheduled is the time the poll was due.
+	Scheduled time.Time
+	// Late is how long after the next due time the poll ended.
+	Late time.Duration
+	// Skipped is the number of polls left out to catch up.
+	Skipped int
+}
+
+// Poller reads points across slaves at their rates on one client handler.
+// Points of a slave and table sharing a rate are coalesced into the fewest
+// read requests, so that
+//
+//	p := modbus.NewPoller(client)
+//	p.MaxGap = 4
+//	p.OnSample = func(s modbus.Sample) { ... }
+//	p.Add(modbus.Point{Name: "voltage", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 100, Quantity: 2, Rate: time.Second},
+//		modbus.Point{Name: "current", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 104, Quantity: 2, Rate: time.Second})
+//	err := p.Run(ctx)
+//
+// reads both points with one request every second. Polls are run one at a
+// time; the handler may be used concurrently by others.
+type Poller struct {
+	// MaxGap is the largest number of unused bits or registers between two
+	// points still read by one request. Zero merges adjacent points only.
+	MaxGap uint16
+	// OnSample, if set, receives every point after each poll.
+	OnSample func(Sample)
+	// OnDeadlineMiss, if set, is called when a poll overruns its period.
+	OnDeadlineMiss func(DeadlineMiss)
+
+	client *ClientHandler
+
+	mu       sync.Mutex
+	points   []Point
+	watchers []*watcher // by point, nil if not watched
+	running  bool
+}
+
+// pollGroup is the set of requests of a slave, table and rate.
+type pollGroup struct {
+	slaveID  byte
+	table    Table
+	rate     time.Duration
+	points   []Point
+	watchers []*watcher
+	spans    []span
+	next     time.Time
+}
+
+// NewPoller creates a poller without points on client.
+func NewPoller(client *ClientHandler) *Poller {
+	return &Poller{client: client}
+}
+
+// Add registers points to poll. It fails while the poller runs, and for
+// registers in Enron ranges.
+func (p *Poller) Add(points ...Point) error {
+	for _, pt := range points {
+		if err := p.add(pt, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add registers a point, watched by w if not nil.
+func (p *Poller) add(pt Point, w *watcher) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return fmt.Errorf("modbus: points cannot be added to a running poller")
+	}
+	if pt.Quantity == 0 {
+		pt.Quantity = 1
+	}
+	if !pt.Table.valid() {
+		return fmt.Errorf("modbus: point '%v' has invalid table '%v'", pt.Name, pt.Table)
+	}
+	if int(pt.Quantity) > pt.Table.maxRead() || int(pt.Address)+int(pt.Quantity) > 65536 {
+		return fmt.Errorf("modbus: point '%v' quantity '%v' at address '%v' exceeds the %v limits", pt.Name, pt.Quantity, pt.Address, pt.Table)
+	}
+	if !pt.Table.IsBits() && p.client.enron.overlaps(pt.Address, pt.Quantity) {
+		return fmt.Errorf("modbus: point '%v' at address '%v' is in an Enron range, see ReadEnronRegisters", pt.Name, pt.Address)
+	}
+	if pt.Rate <= 0 {
+		return fmt.Errorf("modbus: point '%v' rate '%v' must be positive", pt.Name, pt.Rate)
+	}
+	if w != nil {
+		if err := w.init(pt); err != nil {
+			return err
+		}
+	}
+	p.points = append(p.points, pt)
+	p.watchers = append(p.watchers, w)
+	return nil
+}
+
+// Requests returns the number of read requests of one cycle, that is one
+// poll of every rate.
+func (p *Poller) Requests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, g := range p.plan() {
+		n += len(g.spans)
+	}
+	return n
+}
+
+// Run polls until ctx is done and returns its error.
+func (p *Poller) Run(ctx context.Context) error {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return fmt.Errorf("modbus: poller is already running")
+	}
+	p.running = true
+	groups := p.plan()
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running = false
+		p.mu.Unlock()
+	}()
+
+	if len(groups) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	start := time.Now()
+	for _, g := range groups {
+		g.next = start
+	}
+	for {
+		// Groups are few, a linear scan for the earliest is cheap.
+		g := groups[0]
+		for _, other := range groups[1:] {
+			if other.next.Before(g.next) {
+				g = other
+			}
+		}
+		if d := time.Until(g.next); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p.poll(g)
+
+		due := g.next.Add(g.rate)
+		if now := time.Now(); now.After(due) {
+			skipped := int(now.Sub(due)/g.rate) + 1
+			if p.OnDeadlineMiss != nil {
+				p.OnDeadlineMiss(DeadlineMiss{
+					SlaveID:   g.slaveID,
+					Table:     g.table,
+					Rate:      g.rate,
+					Scheduled: g.next,
+					Late:      now.Sub(due),
+					Skipped:   skipped,
+				})
+			}
+			due = due.Add(time.Duration(skipped) * g.rate)
+		}
+		g.next = due
+	}
+}
+
+// poll reads all spans of g and hands the samples to OnSample and the
+// watchers.
+func (p *Poller) poll(g *pollGroup) {
+	for _, sp := range g.spans {
+		bits, registers, err := p.client.readTable(g.slaveID, g.table, sp.address, sp.quantity)
+		now := time.Now()
+		for _, m := range sp.members {
+			pt := g.points[m]
+			s := Sample{Point: pt, Time: now, Err: err}
+			if err == nil {
+				i := int(pt.Address - sp.address)
+				j := i + int(pt.Quantity)
+				if g.table.IsBits() {
+					s.Bits = bits[i:j:j]
+				} else {
+					s.Registers = registers[i:j:j]
+				}
+			}
+			if p.OnSample != nil {
+				p.OnSample(s)
+			}
+			if w := g.watchers[m]; w != nil {
+				w.update(s)
+			}
+		}
+	}
+}
+
+// plan groups the points by slave, table and rate and coalesces each group
+// into read requests. The caller must hold p.mu.
+func (p *Poller) plan() []*pollGroup {
+	type key struct {
+		slaveID byte
+		table   Table
+		rate    time.Duration
+	}
+	index := make(map[key]*pollGroup)
+	var groups []*pollGroup
+	for i, pt := range p.points {
+		k := key{pt.SlaveID, pt.Table, pt.Rate}
+		g := index[k]
+		if g == nil {
+			g = &pollGroup{slaveID: pt.SlaveID, table: pt.Table, rate: pt.Rate}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		g.points = append(g.points, pt)
+		g.watchers = append(g.watchers, p.watchers[i])
+	}
+	for _, g := range groups {
+		points := g.points
+		var enron enronRanges
+		if !g.table.IsBits() {
+			enron = p.client.enron
+		}
+		g.spans = coalesce(len(points), func(i int) (uint16, uint16) {
+			return points[i].Address, points[i].Quantity
+		}, int(p.MaxGap), g.table.maxRead(), enron)
+	}
+	return groups
+}
@@ -0,0 +1,297 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	poolDefaultMaxConns    = 4
+	poolDefaultIdleTimeout = time.Minute
+	poolDefaultWaitTimeout = 10 * time.Second
+)
+
+// DefaultTCPPool is the process-wide connection pool, see WithTCPPool.
+var DefaultTCPPool = &TCPPool{}
+
+// TCPPool shares Modbus TCP connections per address between the clients of
+// a process, e.g. of different unit ids behind one gateway:
+//
+//	meter, err := modbus.Dial("tcp://10.0.0.5?unit=1", modbus.WithTCPPool(modbus.DefaultTCPPool))
+//	drive, err := modbus.Dial("tcp://10.0.0.5?unit=2", modbus.WithTCPPool(modbus.DefaultTCPPool))
+//
+// A client holds a connection for one request only. Idle connections are
+// checked before reuse and dropped if the device closed them or sent
+// unsolicited data. The check waits a millisecond for such data, which adds
+// to the time of every request. The zero value is ready to use.
+type TCPPool struct {
+	// MaxConns limits the connections per address, 4 if zero.
+	MaxConns int
+	// IdleTimeout closes connections idle for longer, 1 minute if zero.
+	IdleTimeout time.Duration
+	// WaitTimeout limits the wait for a free connection, 10 seconds if
+	// zero. Requests waiting longer fail with ErrTimeout.
+	WaitTimeout time.Duration
+
+	mu        sync.Mutex
+	endpoints map[string]*poolEndpoint
+}
+
+// PoolStats are the connections of a pool to an address.
+type PoolStats struct {
+	// Open is the number of connections, InUse those held by a request.
+	Open  int
+	InUse int
+}
+
+// poolEndpoint are the connections to an address.
+type poolEndpoint struct {
+	address string
+	// slots holds a token per connection in use or being dialed.
+	slots chan struct{}
+	idle  []*pooledConn
+	evict *time.Timer
+}
+
+// pooledConn is a connection of a pool.
+type pooledConn struct {
+	tcpConnCategoryPort
+	endpoint  *poolEndpoint
+	idleSince time.Time
+}
+
+func (p *TCPPool) maxConns() int {
+	if p.MaxConns > 0 {
+		return p.MaxConns
+	}
+	return poolDefaultMaxConns
+}
+
+func (p *TCPPool) idleTimeout() time.Duration {
+	if p.IdleTimeout > 0 {
+		return p.IdleTimeout
+	}
+	return poolDefaultIdleTimeout
+}
+
+func (p *TCPPool) waitTimeout() time.Duration {
+	if p.WaitTimeout > 0 {
+		return p.WaitTimeout
+	}
+	return poolDefaultWaitTimeout
+}
+
+// endpoint returns the connections to address.
+func (p *TCPPool) endpoint(address string) *poolEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.endpoints == nil {
+		p.endpoints = make(map[string]*poolEndpoint)
+	}
+	e := p.endpoints[address]
+	if e == nil {
+		e = &poolEndpoint{address: address, slots: make(chan struct{}, p.maxConns())}
+		p.endpoints[address] = e
+	}
+	return e
+}
+
+// Stats returns the connections of p to address.
+func (p *TCPPool) Stats(address string) PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoints[address]
+	if e == nil {
+		return PoolStats{}
+	}
+	inUse := len(e.slots)
+	return PoolStats{Open: inUse + len(e.idle), InUse: inUse}
+}
+
+// Close closes the idle connections of p. Connections in use are closed
+// when returned.
+func (p *TCPPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.endpoints {
+		for _, c := range e.idle {
+			c.conn.Close()
+		}
+		e.idle = nil
+	}
+	p.endpoints = nil
+	return nil
+}
+
+// get returns a healthy connection to address, dialing one if none is idle
+// and the limit allows.
+func (p *TCPPool) get(address string, connectTimeout time.Duration) (*pooledConn, error) {
+	e := p.endpoint(address)
+	timer := time.NewTimer(p.waitTimeout())
+	defer timer.Stop()
+	select {
+	case e.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, errorf(ErrTimeout, "modbus: no free connection to '%v' within %v", address, p.waitTimeout())
+	}
+	for {
+		p.mu.Lock()
+		var c *pooledConn
+		if n := len(e.idle); n > 0 {
+			c = e.idle[n-1]
+			e.idle = e.idle[:n-1]
+		}
+		p.mu.Unlock()
+		if c == nil {
+			break
+		}
+		if time.Since(c.idleSince) < p.idleTimeout() && c.healthy() {
+			return c, nil
+		}
+		c.conn.Close()
+	}
+	dialer := net.Dialer{Timeout: connectTimeout}
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		<-e.slots
+		return nil, err
+	}
+	return &pooledConn{tcpConnCategoryPort: tcpConnCategoryPort{conn: conn}, endpoint: e}, nil
+}
+
+// put returns c to the idle connections of its endpoint.
+func (p *TCPPool) put(c *pooledConn) {
+	e := c.endpoint
+	c.idleSince = time.Now()
+	p.mu.Lock()
+	if p.endpoints[e.address] != e {
+		// Closed meanwhile
+		c.conn.Close()
+	} else {
+		e.idle = append(e.idle, c)
+		if e.evict == nil {
+			e.evict = time.AfterFunc(p.idleTimeout(), func() { p.evict(e) })
+		}
+	}
+	p.mu.Unlock()
+	<-e.slots
+}
+
+// discard closes c, e.g. after a failed request.
+func (p *TCPPool) discard(c *pooledConn) {
+	c.conn.Close()
+	<-c.endpoint.slots
+}
+
+// evict closes the connections of e idle for longer than the idle timeout.
+func (p *TCPPool) evict(e *poolEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.evict = nil
+	timeout := p.idleTimeout()
+	// Idle connections are in the order they were returned
+	n := 0
+	for _, c := range e.idle {
+		if time.Since(c.idleSince) < timeout {
+			break
+		}
+		c.conn.Close()
+		n++
+	}
+	e.idle = append(e.idle[:0], e.idle[n:]...)
+	if len(e.idle) > 0 {
+		e.evict = time.AfterFunc(timeout-time.Since(e.idle[0].idleSince), func() { p.evict(e) })
+	}
+}
+
+// healthy reports whether the device kept c open and sent nothing while it
+// was idle, waiting a millisecond for a byte or the end of the stream. It
+// clears the read deadline of the probe, as requests without a
+// timeout do not set one.
+func (c *pooledConn) healthy() bool {
+	if err := c.conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	var b [1]byte
+	n, err := c.conn.Read(b[:])
+	if n > 0 {
+		return false
+	}
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		return false
+	}
+	return c.conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// pooledTransport is the transporter of a client of a pool. It holds a
+// connection from Connect until the request is done.
+type pooledTransport struct {
+	pool           *TCPPool
+	address        string
+	connectTimeout time.Duration
+	conn           *pooledConn
+}
+
+var errNotConnected = errors.New("modbus: pooled connection is not connected")
+
+func (t *pooledTransport) Connect() (err error) {
+	if t.conn == nil {
+		t.conn, err = t.pool.get(t.address, t.connectTimeout)
+	}
+	return
+}
+
+func (t *pooledTransport) Read(b []byte) (n int, err error) {
+	if t.conn == nil {
+		return 0, errNotConnected
+	}
+	return t.conn.Read(b)
+}
+
+func (t *pooledTransport) Write(b []byte) (n int, err error) {
+	if t.conn == nil {
+		return 0, errNotConnected
+	}
+	return t.conn.Write(b)
+}
+
+// Close drops the connection held, which is not returned to the pool.
+func (t *pooledTransport) Close() error {
+	if t.conn != nil {
+		t.pool.discard(t.conn)
+		t.conn = nil
+	}
+	return nil
+}
+
+func (t *pooledTransport) SetReadTimeout(timeout time.Duration) error {
+	if t.conn == nil {
+		return errNotConnected
+	}
+	return t.conn.SetReadTimeout(timeout)
+}
+
+func (t *pooledTransport) Flush() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Flush()
+}
+
+// done returns the connection of a pooled transporter to its pool after a
+// request. It drops the connection if err is neither nil nor an exception
+// response, as a late response may still arrive. The caller must hold c.mu.
+func (c *ClientHandler) done(err error) {
+	t, ok := c.Transporter.(*pooledTransport)
+	if !ok || t.conn == nil {
+		return
+	}
+	if _, exception := err.(*ModbusError); err != nil && !exception {
+		t.Close()
+		return
+	}
+	t.pool.put(t.conn)
+	t.conn = nil
+}
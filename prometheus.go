@@ -0,0 +1,283 @@
+package modbus
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets used by NewPrometheusMetrics.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics implements Metrics and QueueMetrics and serves the
+// collected values over HTTP in the Prometheus text exposition format. One
+// instance is meant to be shared by all client handlers of a process:
+//
+//	metrics := modbus.NewPrometheusMetrics()
+//	http.Handle("/metrics", metrics)
+//	client, err := modbus.Dial(url, modbus.WithMetrics(metrics))
+type PrometheusMetrics struct {
+	mu         sync.Mutex
+	buckets    []float64
+	requests   map[MetricLabels]*latencyHistogram
+	failures   map[MetricLabels]uint64
+	exceptions map[exceptionLabels]uint64
+	timeouts   map[MetricLabels]uint64
+	checksums  map[MetricLabels]uint64
+	reconnects map[endpointLabels]uint64
+	queueWaits map[MetricLabels]*latencyHistogram
+	dropped    map[MetricLabels]uint64
+	queueDepth map[endpointLabels]int
+}
+
+type exceptionLabels struct {
+	MetricLabels
+	code byte
+}
+
+type endpointLabels struct {
+	transport string
+	endpoint  string
+}
+
+type latencyHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics creates an empty collector. buckets are the latency
+// histogram upper bounds in seconds, DefaultLatencyBuckets if none given.
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusMetrics{
+		buckets:    buckets,
+		requests:   make(map[MetricLabels]*latencyHistogram),
+		failures:   make(map[MetricLabels]uint64),
+		exceptions: make(map[exceptionLabels]uint64),
+		timeouts:   make(map[MetricLabels]uint64),
+		checksums:  make(map[MetricLabels]uint64),
+		reconnects: make(map[endpointLabels]uint64),
+		queueWaits: make(map[MetricLabels]*latencyHistogram),
+		dropped:    make(map[MetricLabels]uint64),
+		queueDepth: make(map[endpointLabels]int),
+	}
+}
+
+func (m *PrometheusMetrics) ObserveRequest(labels MetricLabels, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observe(m.requests, labels, latency)
+	if err != nil {
+		m.failures[labels]++
+	}
+}
+
+// ObserveQueue implements QueueMetrics.
+func (m *PrometheusMetrics) ObserveQueue(labels MetricLabels, wait time.Duration, depth int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observe(m.queueWaits, labels, wait)
+	m.queueDepth[endpointLabels{labels.Transport, labels.Endpoint}] = depth
+	if err != nil {
+		m.dropped[labels]++
+	}
+}
+
+// observe adds d to the histogram of labels. The caller must hold m.mu.
+func (m *PrometheusMetrics) observe(histograms map[MetricLabels]*latencyHistogram, labels MetricLabels, d time.Duration) {
+	h := histograms[labels]
+	if h == nil {
+		h = &latencyHistogram{counts: make([]uint64, len(m.buckets))}
+		histograms[labels] = h
+	}
+	seconds := d.Seconds()
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *PrometheusMetrics) ObserveException(labels MetricLabels, exceptionCode byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exceptions[exceptionLabels{labels, exceptionCode}]++
+}
+
+func (m *PrometheusMetrics) ObserveTimeout(labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts[labels]++
+}
+
+func (m *PrometheusMetrics) ObserveChecksumError(labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checksums[labels]++
+}
+
+func (m *PrometheusMetrics) ObserveReconnect(labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnects[endpointLabels{labels.Transport, labels.Endpoint}]++
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := bufio.NewWriter(w)
+	m.write(b)
+	b.Flush()
+}
+
+func (m *PrometheusMetrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]MetricLabels, 0, len(m.requests))
+	for l := range m.requests {
+		keys = append(keys, l)
+	}
+	sortLabels(keys)
+
+	writeHeader(w, "modbus_requests_total", "counter", "Requests sent, after retries.")
+	for _, l := range keys {
+		fmt.Fprintf(w, "modbus_requests_total{%s} %d\n", formatLabels(l), m.requests[l].count)
+	}
+	writeCounters(w, "modbus_request_failures_total", "Requests that failed, including exceptions.", m.failures)
+	writeCounters(w, "modbus_timeouts_total", "Attempts that timed out.", m.timeouts)
+	writeCounters(w, "modbus_checksum_errors_total", "Responses failing the CRC or LRC check.", m.checksums)
+
+	writeHeader(w, "modbus_exceptions_total", "counter", "Exception responses by exception code.")
+	exceptions := make([]exceptionLabels, 0, len(m.exceptions))
+	for l := range m.exceptions {
+		exceptions = append(exceptions, l)
+	}
+	sort.Slice(exceptions, func(i, j int) bool {
+		if exceptions[i].MetricLabels != exceptions[j].MetricLabels {
+			return lessLabels(exceptions[i].MetricLabels, exceptions[j].MetricLabels)
+		}
+		return exceptions[i].code < exceptions[j].code
+	})
+	for _, l := range exceptions {
+		fmt.Fprintf(w, "modbus_exceptions_total{%s,code=\"%d\"} %d\n", formatLabels(l.MetricLabels), l.code, m.exceptions[l])
+	}
+
+	writeHeader(w, "modbus_reconnects_total", "counter", "Connections dropped to be re-established.")
+	reconnects := make([]endpointLabels, 0, len(m.reconnects))
+	for l := range m.reconnects {
+		reconnects = append(reconnects, l)
+	}
+	sort.Slice(reconnects, func(i, j int) bool {
+		if reconnects[i].transport != reconnects[j].transport {
+			return reconnects[i].transport < reconnects[j].transport
+		}
+		return reconnects[i].endpoint < reconnects[j].endpoint
+	})
+	for _, l := range reconnects {
+		fmt.Fprintf(w, "modbus_reconnects_total{transport=%s,endpoint=%s} %d\n",
+			quoteLabel(l.transport), quoteLabel(l.endpoint), m.reconnects[l])
+	}
+
+	m.writeHistograms(w, "modbus_request_duration_seconds", "Request latency, including retries.", m.requests)
+
+	if len(m.queueWaits) == 0 {
+		return
+	}
+	writeCounters(w, "modbus_queue_dropped_total", "Requests dropped after waiting past their deadline.", m.dropped)
+	writeHeader(w, "modbus_queue_depth", "gauge", "Requests found waiting by the last request queued.")
+	depths := make([]endpointLabels, 0, len(m.queueDepth))
+	for l := range m.queueDepth {
+		depths = append(depths, l)
+	}
+	sort.Slice(depths, func(i, j int) bool {
+		if depths[i].transport != depths[j].transport {
+			return depths[i].transport < depths[j].transport
+		}
+		return depths[i].endpoint < depths[j].endpoint
+	})
+	for _, l := range depths {
+		fmt.Fprintf(w, "modbus_queue_depth{transport=%s,endpoint=%s} %d\n",
+			quoteLabel(l.transport), quoteLabel(l.endpoint), m.queueDepth[l])
+	}
+	m.writeHistograms(w, "modbus_queue_wait_seconds", "Time requests waited for their turn in the queue.", m.queueWaits)
+}
+
+func (m *PrometheusMetrics) writeHistograms(w *bufio.Writer, name, help string, histograms map[MetricLabels]*latencyHistogram) {
+	writeHeader(w, name, "histogram", help)
+	keys := make([]MetricLabels, 0, len(histograms))
+	for l := range histograms {
+		keys = append(keys, l)
+	}
+	sortLabels(keys)
+	for _, l := range keys {
+		h := histograms[l]
+		labels := formatLabels(l)
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n",
+				name, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeCounters(w *bufio.Writer, name, help string, counters map[MetricLabels]uint64) {
+	writeHeader(w, name, "counter", help)
+	keys := make([]MetricLabels, 0, len(counters))
+	for l := range counters {
+		keys = append(keys, l)
+	}
+	sortLabels(keys)
+	for _, l := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, formatLabels(l), counters[l])
+	}
+}
+
+func sortLabels(keys []MetricLabels) {
+	sort.Slice(keys, func(i, j int) bool {
+		return lessLabels(keys[i], keys[j])
+	})
+}
+
+func lessLabels(a, b MetricLabels) bool {
+	if a.Transport != b.Transport {
+		return a.Transport < b.Transport
+	}
+	if a.Endpoint != b.Endpoint {
+		return a.Endpoint < b.Endpoint
+	}
+	if a.SlaveID != b.SlaveID {
+		return a.SlaveID < b.SlaveID
+	}
+	return a.FunctionCode < b.FunctionCode
+}
+
+func formatLabels(l MetricLabels) string {
+	return fmt.Sprintf("transport=%s,endpoint=%s,slave=\"%d\",function=\"%d\"",
+		quoteLabel(l.Transport), quoteLabel(l.Endpoint), l.SlaveID, l.FunctionCode)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,175 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// QueueStats are statistics of a request queue, see WithPriority and
+// WithMaxWait.
+type QueueStats struct {
+	// Requests is the number of turns taken.
+	Requests uint64
+	// Dropped is the number of requests dropped with ErrDeadlineExceeded.
+	Dropped uint64
+	// Queued is the number of requests waiting for their turn.
+	Queued int
+	// Wait is the total and MaxWait the longest time requests waited.
+	Wait    time.Duration
+	MaxWait time.Duration
+}
+
+// requestQueue serves requests one at a time, the highest priority first,
+// then the earliest deadline and then in arrival order.
+type requestQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiters []*queueWaiter
+	stats   QueueStats
+}
+
+// queueWaiter is a request waiting for its turn.
+type queueWaiter struct {
+	priority int
+	deadline time.Time
+	ready    chan struct{}
+}
+
+// before reports whether w is served before v, which arrived earlier.
+func (w *queueWaiter) before(v *queueWaiter) bool {
+	if w.priority != v.priority {
+		return w.priority > v.priority
+	}
+	if w.deadline.IsZero() {
+		return false
+	}
+	return v.deadline.IsZero() || w.deadline.Before(v.deadline)
+}
+
+// acquire waits for the turn of a request, to be ended with release. The
+// request is dropped if deadline, unless zero, passes before its turn.
+// depth is the number of requests it found waiting.
+func (q *requestQueue) acquire(priority int, deadline time.Time) (wait time.Duration, depth int, err error) {
+	start := time.Now()
+	q.mu.Lock()
+	depth = len(q.waiters)
+	if !q.busy && depth == 0 {
+		q.busy = true
+		q.stats.Requests++
+		q.mu.Unlock()
+		return
+	}
+	w := &queueWaiter{priority: priority, deadline: deadline, ready: make(chan struct{})}
+	q.waiters = append(q.waiters, w)
+	q.mu.Unlock()
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-w.ready:
+	case <-expired:
+	}
+	wait = time.Since(start)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turn := !q.remove(w)
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		if turn {
+			// Handed the turn as the deadline passed
+			<-w.ready
+			q.next()
+		}
+		q.stats.Dropped++
+		err = errorf(ErrDeadlineExceeded, "modbus: request dropped after waiting %v in the queue", wait)
+		return
+	}
+	q.stats.Requests++
+	q.stats.Wait += wait
+	if wait > q.stats.MaxWait {
+		q.stats.MaxWait = wait
+	}
+	return
+}
+
+// remove removes w from the waiters and reports whether it was waiting.
+// The caller must hold q.mu.
+func (q *requestQueue) remove(w *queueWaiter) bool {
+	for i, v := range q.waiters {
+		if v == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release hands the turn to the next request, if any.
+func (q *requestQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.next()
+}
+
+// next hands the turn to the next request. The caller must hold q.mu.
+func (q *requestQueue) next() {
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	// The waiters are in arrival order
+	next := 0
+	for i, w := range q.waiters {
+		if w.before(q.waiters[next]) {
+			next = i
+		}
+	}
+	w := q.waiters[next]
+	q.waiters = append(q.waiters[:next], q.waiters[next+1:]...)
+	close(w.ready)
+}
+
+// snapshot returns the statistics of q.
+func (q *requestQueue) snapshot() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Queued = len(q.waiters)
+	return stats
+}
+
+// turn waits for the turn of a request with functionCode in the queue of
+// c, if any, and returns the function ending it.
+func (c *ClientHandler) turn(functionCode byte) (release func(), err error) {
+	if c.queue == nil {
+		return func() {}, nil
+	}
+	var deadline time.Time
+	if c.maxWait > 0 {
+		deadline = time.Now().Add(c.maxWait)
+	}
+	wait, depth, err := c.queue.acquire(c.priority, deadline)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.metrics.(QueueMetrics); ok {
+		m.ObserveQueue(c.metricLabels(functionCode), wait, depth, err)
+	}
+	if err != nil {
+		c.annotate(err, functionCode)
+		return nil, err
+	}
+	return c.queue.release, nil
+}
+
+// QueueStats returns the statistics of the request queue of c, shared with
+// the other clients of its bus.
+func (c *ClientHandler) QueueStats() QueueStats {
+	if c.queue == nil {
+		return QueueStats{}
+	}
+	return c.queue.snapshot()
+}
@@ -0,0 +1,177 @@
+package modbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ref is a bit or register resolved from the notation of a register map.
+type Ref struct {
+	Table Table
+	// Address is the zero-based protocol address.
+	Address uint16
+}
+
+// String formats r in 6-digit Modicon notation, such as 400001 for the
+// holding register at address 0.
+func (r Ref) String() string {
+	return fmt.Sprintf("%c%05d", refPrefix(r.Table), int(r.Address)+1)
+}
+
+// refPrefix returns the Modicon digit of t.
+func refPrefix(t Table) byte {
+	switch t {
+	case TableCoils:
+		return '0'
+	case TableDiscreteInputs:
+		return '1'
+	case TableInputRegisters:
+		return '3'
+	case TableHoldingRegisters:
+		return '4'
+	}
+	return '?'
+}
+
+// refTable returns the table of a Modicon digit, zero if unknown.
+func refTable(prefix byte) Table {
+	switch prefix {
+	case '0':
+		return TableCoils
+	case '1':
+		return TableDiscreteInputs
+	case '3':
+		return TableInputRegisters
+	case '4':
+		return TableHoldingRegisters
+	}
+	return 0
+}
+
+// iecPrefixes are the IEC 61131-3 located variables, longest first.
+var iecPrefixes = []struct {
+	prefix string
+	table  Table
+}{
+	{"%MW", TableHoldingRegisters},
+	{"%IW", TableInputRegisters},
+	{"%M", TableCoils},
+	{"%I", TableDiscreteInputs},
+}
+
+// ParseRef resolves the notations of register maps into a table and a
+// zero-based address:
+//
+//	40108     5-digit Modicon, register 108 of the holding registers
+//	400108    6-digit Modicon, up to register 65536
+//	4x0108    prefixed, also 3x, 1x and 0x, of any length
+//	%MW107    IEC 61131-3: %MW, %IW, %M and %I, always zero-based
+//
+// The Modicon notations number registers from 1 unless zeroBased, for
+// devices whose maps start at 40000.
+func ParseRef(s string, zeroBased bool) (ref Ref, err error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	for _, p := range iecPrefixes {
+		if strings.HasPrefix(upper, p.prefix) {
+			return parseRefNumber(s, p.table, upper[len(p.prefix):], 65535, true)
+		}
+	}
+	var table Table
+	var number string
+	max := 65535
+	switch {
+	case len(s) > 2 && (s[1] == 'x' || s[1] == 'X'):
+		table, number = refTable(s[0]), s[2:]
+	case len(s) == 5:
+		table, number, max = refTable(s[0]), s[1:], 9999
+	case len(s) == 6:
+		table, number = refTable(s[0]), s[1:]
+	}
+	if table == 0 {
+		err = fmt.Errorf("modbus: unknown address notation '%v'", s)
+		return
+	}
+	if !zeroBased {
+		max++
+	}
+	return parseRefNumber(s, table, number, max, zeroBased)
+}
+
+// parseRefNumber parses the register number of notation s, at most max.
+func parseRefNumber(s string, table Table, number string, max int, zeroBased bool) (ref Ref, err error) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 || number[0] == '+' {
+		err = fmt.Errorf("modbus: invalid register number in '%v'", s)
+		return
+	}
+	if !zeroBased {
+		if n == 0 {
+			err = fmt.Errorf("modbus: register number in '%v' must start at 1", s)
+			return
+		}
+		n--
+		max--
+	}
+	if n > max {
+		err = fmt.Errorf("modbus: register number in '%v' is out of range", s)
+		return
+	}
+	return Ref{Table: table, Address: uint16(n)}, nil
+}
+
+// ParseRef resolves a register map notation, zero-based if configured with
+// WithZeroBasedRefs.
+func (c *ClientHandler) ParseRef(s string) (Ref, error) {
+	return ParseRef(s, c.zeroBased)
+}
+
+// Read reads quantity bits or registers at the notation ref, see ParseRef,
+// with the function code of its table.
+func (c *ClientHandler) Read(ref string, quantity uint16) (bits []bool, registers []uint16, err error) {
+	r, err := c.ParseRef(ref)
+	if err != nil {
+		return
+	}
+	switch r.Table {
+	case TableCoils:
+		bits, err = c.ReadCoils(r.Address, quantity)
+	case TableDiscreteInputs:
+		bits, err = c.ReadDiscreteInputs(r.Address, quantity)
+	case TableInputRegisters:
+		registers, err = c.ReadInputRegisters(r.Address, quantity)
+	case TableHoldingRegisters:
+		registers, err = c.ReadHoldingRegisters(r.Address, quantity)
+	}
+	return
+}
+
+// Write writes v at the notation ref, see ParseRef: a bool or []bool to
+// coils, a uint16 or []uint16 to holding registers. Single values use the
+// single write function codes.
+func (c *ClientHandler) Write(ref string, v interface{}) (err error) {
+	r, err := c.ParseRef(ref)
+	if err != nil {
+		return
+	}
+	switch value := v.(type) {
+	case bool:
+		if r.Table == TableCoils {
+			return c.WriteSingleCoil(r.Address, value)
+		}
+	case []bool:
+		if r.Table == TableCoils {
+			return c.WriteMultipleCoils(r.Address, value)
+		}
+	case uint16:
+		if r.Table == TableHoldingRegisters {
+			return c.WriteSingleRegister(r.Address, value)
+		}
+	case []uint16:
+		if r.Table == TableHoldingRegisters {
+			return c.WriteMultipleRegisters(r.Address, value)
+		}
+	}
+	return fmt.Errorf("modbus: %T cannot be written to %v", v, r.Table)
+}
@@ -0,0 +1,308 @@
+package modbus
+
+import (
+	"encoding/binary"
+)
+
+// request is a request of one of the functions of Client with its
+// arguments checked, and the decoding of its response. ClientHandler, Async
+// and the *Large methods build their requests with the functions below.
+type request struct {
+	pdu ProtocolDataUnit
+	// err reports invalid arguments, the request is not sent.
+	err error
+	// decode checks the response and returns the bits or registers read.
+	decode func(response *ProtocolDataUnit) result
+	// address and the coils or registers written, read back with
+	// WithWriteVerify.
+	address   uint16
+	coils     []bool
+	registers []uint16
+}
+
+// result is the outcome of a request.
+type result struct {
+	bits      []bool
+	registers []uint16
+	err       error
+}
+
+// writes reports whether r changes the device, so that sending it twice
+// may not be the same as sending it once.
+func (r *request) writes() bool {
+	switch r.pdu.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs, FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters, FuncCodeReadFIFOQueue:
+		return false
+	}
+	return true
+}
+
+// invalidRequest returns a request failing with an ErrInvalidQuantity
+// error.
+func invalidRequest(functionCode byte, format string, v ...interface{}) *request {
+	return &request{
+		pdu: ProtocolDataUnit{FunctionCode: functionCode},
+		err: requestErrorf(ErrInvalidQuantity, functionCode, format, v...),
+	}
+}
+
+// requestErrorf creates an Error of the given kind for a request with
+// functionCode. The slave is added when the request is sent.
+func requestErrorf(kind error, functionCode byte, format string, v ...interface{}) *Error {
+	e := errorf(kind, format, v...)
+	e.FunctionCode = functionCode
+	return e
+}
+
+// readBitsRequest reads quantity coils or discrete inputs.
+func readBitsRequest(functionCode byte, address, quantity uint16) *request {
+	if quantity < 1 || quantity > MaxReadBits {
+		return invalidRequest(functionCode, "modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxReadBits)
+	}
+	return &request{
+		pdu: ProtocolDataUnit{FunctionCode: functionCode, Data: dataBlock(address, quantity)},
+		decode: func(response *ProtocolDataUnit) (res result) {
+			res.bits, res.err = bitsResponse(response, quantity)
+			return
+		},
+	}
+}
+
+// readRegistersRequest reads quantity holding or input registers, 32-bit
+// ones in the Enron ranges given.
+func readRegistersRequest(enron enronRanges, functionCode byte, address, quantity uint16) *request {
+	wide, err := enron.quantity(functionCode, address, quantity)
+	if err != nil {
+		return &request{pdu: ProtocolDataUnit{FunctionCode: functionCode}, err: err}
+	}
+	words := quantity
+	if wide {
+		words *= 2
+	}
+	return &request{
+		pdu: ProtocolDataUnit{FunctionCode: functionCode, Data: dataBlock(address, quantity)},
+		decode: func(response *ProtocolDataUnit) (res result) {
+			res.registers, res.err = registersResponse(response, words)
+			return
+		},
+	}
+}
+
+// writeSingleCoilRequest sets a coil ON or OFF.
+func writeSingleCoilRequest(address uint16, coil bool) *request {
+	// The requested ON/OFF state can only be 0xFF00 and 0x0000
+	var value uint16
+	if coil {
+		value = 0xFF00
+	}
+	return &request{
+		pdu:     ProtocolDataUnit{FunctionCode: FuncCodeWriteSingleCoil, Data: dataBlock(address, value)},
+		decode:  echo(address, "value", value),
+		address: address,
+		coils:   []bool{coil},
+	}
+}
+
+// writeMultipleCoilsRequest sets coils.
+func writeMultipleCoilsRequest(address uint16, coils []bool) *request {
+	count := len(coils)
+	if count < 1 || count > MaxWriteCoils {
+		return invalidRequest(FuncCodeWriteMultipleCoils, "modbus: quantity '%v' (len(coils)) must be between '%v' and '%v'", count, 1, MaxWriteCoils)
+	}
+	quantity := uint16(count)
+	return &request{
+		pdu: ProtocolDataUnit{
+			FunctionCode: FuncCodeWriteMultipleCoils,
+			Data:         dataBlockSuffix(packBits(coils), address, quantity),
+		},
+		decode:  echo(address, "quantity", quantity),
+		address: address,
+		coils:   coils,
+	}
+}
+
+// writeSingleRegisterRequest writes a holding register.
+func writeSingleRegisterRequest(address, value uint16) *request {
+	return &request{
+		pdu:       ProtocolDataUnit{FunctionCode: FuncCodeWriteSingleRegister, Data: dataBlock(address, value)},
+		decode:    echo(address, "value", value),
+		address:   address,
+		registers: []uint16{value},
+	}
+}
+
+// writeMultipleRegistersRequest writes holding registers.
+func writeMultipleRegistersRequest(address uint16, values []uint16) *request {
+	count := len(values)
+	if count < 1 || count > MaxWriteRegisters {
+		return invalidRequest(FuncCodeWriteMultipleRegisters, "modbus: quantity '%v' must be between '%v' and '%v'", count, 1, MaxWriteRegisters)
+	}
+	quantity := uint16(count)
+	return &request{
+		pdu: ProtocolDataUnit{
+			FunctionCode: FuncCodeWriteMultipleRegisters,
+			Data:         dataBlockSuffix(dataBlock(values...), address, quantity),
+		},
+		decode:    echo(address, "quantity", quantity),
+		address:   address,
+		registers: values,
+	}
+}
+
+// maskWriteRegisterRequest changes bits of a holding register.
+func maskWriteRegisterRequest(address, andMask, orMask uint16) *request {
+	return &request{
+		pdu: ProtocolDataUnit{FunctionCode: FuncCodeMaskWriteRegister, Data: dataBlock(address, andMask, orMask)},
+		decode: func(response *ProtocolDataUnit) result {
+			return result{err: maskWriteResponse(response, address, andMask, orMask)}
+		},
+	}
+}
+
+// readWriteMultipleRegistersRequest writes and then reads holding
+// registers.
+func readWriteMultipleRegistersRequest(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) *request {
+	if readQuantity < 1 || readQuantity > MaxReadRegisters {
+		return invalidRequest(FuncCodeReadWriteMultipleRegisters, "modbus: quantity to read '%v' must be between '%v' and '%v'", readQuantity, 1, MaxReadRegisters)
+	}
+	if writeQuantity < 1 || writeQuantity > MaxReadWriteRegisters {
+		return invalidRequest(FuncCodeReadWriteMultipleRegisters, "modbus: quantity to write '%v' must be between '%v' and '%v'", writeQuantity, 1, MaxReadWriteRegisters)
+	}
+	return &request{
+		pdu: ProtocolDataUnit{
+			FunctionCode: FuncCodeReadWriteMultipleRegisters,
+			Data:         dataBlockSuffix(value, readAddress, readQuantity, writeAddress, writeQuantity),
+		},
+		decode: func(response *ProtocolDataUnit) (res result) {
+			res.registers, res.err = registersResponse(response, readQuantity)
+			return
+		},
+	}
+}
+
+// readFIFOQueueRequest reads the registers of a FIFO queue.
+func readFIFOQueueRequest(address uint16) *request {
+	return &request{
+		pdu: ProtocolDataUnit{FunctionCode: FuncCodeReadFIFOQueue, Data: dataBlock(address)},
+		decode: func(response *ProtocolDataUnit) (res result) {
+			res.registers, res.err = fifoResponse(response)
+			return
+		},
+	}
+}
+
+// echo returns the decoding of a write response that repeats address and
+// the value or quantity of the request.
+func echo(address uint16, name string, value uint16) func(response *ProtocolDataUnit) result {
+	return func(response *ProtocolDataUnit) result {
+		return result{err: echoResponse(response, address, name, value)}
+	}
+}
+
+// bitsResponse decodes the status of quantity coils or discrete inputs.
+func bitsResponse(response *ProtocolDataUnit, quantity uint16) (bits []bool, err error) {
+	byteCount := int(response.Data[0])
+	length := len(response.Data) - 1
+	if byteCount != length {
+		err = requestErrorf(ErrLengthMismatch, response.FunctionCode, "modbus: response data size '%v' does not match count '%v'", length, byteCount)
+		return
+	}
+	if expected := (int(quantity) + 7) / 8; byteCount < expected {
+		err = requestErrorf(ErrLengthMismatch, response.FunctionCode, "modbus: response byte count '%v' is less than expected '%v'", byteCount, expected)
+		return
+	}
+
+	status := response.Data[1:]
+	bits = make([]bool, byteCount*8)
+	for i := 0; i < byteCount; i++ {
+		for j := 0; j < 8; j++ {
+			bits[i*8+j] = (status[i] & (1 << uint(j))) != 0
+		}
+	}
+	return bits[0:quantity], nil
+}
+
+// registersResponse decodes the values of a read response of quantity
+// words.
+func registersResponse(response *ProtocolDataUnit, quantity uint16) (values []uint16, err error) {
+	count := int(response.Data[0])
+	length := len(response.Data) - 1
+	if count != length {
+		err = requestErrorf(ErrLengthMismatch, response.FunctionCode, "modbus: response data size '%v' does not match count '%v'", length, count)
+		return
+	}
+	if expected := 2 * int(quantity); count != expected {
+		err = requestErrorf(ErrLengthMismatch, response.FunctionCode, "modbus: response byte count '%v' does not match expected '%v'", count, expected)
+		return
+	}
+	values = bytesToWordArray(response.Data[1:])
+	return
+}
+
+// echoResponse checks that a write response repeats the address and the
+// value or quantity of the request.
+func echoResponse(response *ProtocolDataUnit, address uint16, name string, value uint16) (err error) {
+	// Fixed response length
+	if len(response.Data) != 4 {
+		err = requestErrorf(ErrLengthMismatch, response.FunctionCode, "modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
+		return
+	}
+	respValue := binary.BigEndian.Uint16(response.Data)
+	if address != respValue {
+		err = requestErrorf(ErrEchoMismatch, response.FunctionCode, "modbus: response address '%v' does not match request '%v'", respValue, address)
+		return
+	}
+	respValue = binary.BigEndian.Uint16(response.Data[2:])
+	if value != respValue {
+		err = requestErrorf(ErrEchoMismatch, response.FunctionCode, "modbus: response %s '%v' does not match request '%v'", name, respValue, value)
+		return
+	}
+	return
+}
+
+// maskWriteResponse checks that a mask write response repeats the address
+// and masks of the request.
+func maskWriteResponse(response *ProtocolDataUnit, address, andMask, orMask uint16) (err error) {
+	// Fixed response length
+	if len(response.Data) != 6 {
+		err = requestErrorf(ErrLengthMismatch, FuncCodeMaskWriteRegister, "modbus: response data size '%v' does not match expected '%v'", len(response.Data), 6)
+		return
+	}
+	respValue := binary.BigEndian.Uint16(response.Data)
+	if address != respValue {
+		err = requestErrorf(ErrEchoMismatch, FuncCodeMaskWriteRegister, "modbus: response address '%v' does not match request '%v'", respValue, address)
+		return
+	}
+	respValue = binary.BigEndian.Uint16(response.Data[2:])
+	if andMask != respValue {
+		err = requestErrorf(ErrEchoMismatch, FuncCodeMaskWriteRegister, "modbus: response AND-mask '%v' does not match request '%v'", respValue, andMask)
+		return
+	}
+	respValue = binary.BigEndian.Uint16(response.Data[4:])
+	if orMask != respValue {
+		err = requestErrorf(ErrEchoMismatch, FuncCodeMaskWriteRegister, "modbus: response OR-mask '%v' does not match request '%v'", respValue, orMask)
+		return
+	}
+	return
+}
+
+// fifoResponse decodes the register values of a FIFO queue.
+func fifoResponse(response *ProtocolDataUnit) (values []uint16, err error) {
+	if len(response.Data) < 4 {
+		err = requestErrorf(ErrLengthMismatch, FuncCodeReadFIFOQueue, "modbus: response data size '%v' is less than expected '%v'", len(response.Data), 4)
+		return
+	}
+	count := int(binary.BigEndian.Uint16(response.Data))
+	if count != (len(response.Data) - 1) {
+		err = requestErrorf(ErrLengthMismatch, FuncCodeReadFIFOQueue, "modbus: response data size '%v' does not match count '%v'", len(response.Data)-1, count)
+		return
+	}
+	count = int(binary.BigEndian.Uint16(response.Data[2:]))
+	if count > 31 {
+		err = requestErrorf(ErrLengthMismatch, FuncCodeReadFIFOQueue, "modbus: fifo count '%v' is greater than expected '%v'", count, 31)
+		return
+	}
+	values = bytesToWordArray(response.Data[4:])
+	return
+}
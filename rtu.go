@@ -2,7 +2,6 @@ package modbus
 
 import (
 	"encoding/binary"
-	"fmt"
 	"io"
 	"time"
 )
@@ -26,7 +25,7 @@ type RTUPackager struct {
 func (rtu *RTUPackager) Encode(slaveID byte, pdu *ProtocolDataUnit) (adu []byte, err error) {
 	length := len(pdu.Data) + 4
 	if length > rtuMaxSize {
-		err = fmt.Errorf("modbus: length of data '%v' must not be bigger than '%v'", length, rtuMaxSize)
+		err = errorf(ErrInvalidQuantity, "modbus: length of data '%v' must not be bigger than '%v'", length, rtuMaxSize)
 		return
 	}
 	adu = make([]byte, length)
@@ -50,12 +49,12 @@ func (rtu *RTUPackager) Verify(aduRequest []byte, aduResponse []byte) (err error
 	length := len(aduResponse)
 	// Minimum size (including address, function and CRC)
 	if length < rtuMinSize {
-		err = fmt.Errorf("modbus: response length '%v' does not meet minimum '%v'", length, rtuMinSize)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response length '%v' does not meet minimum '%v'", length, rtuMinSize)
 		return
 	}
 	// Slave address must match
 	if aduResponse[0] != aduRequest[0] {
-		err = fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", aduResponse[0], aduRequest[0])
+		err = frameErrorf(ErrUnitIDMismatch, aduResponse, "modbus: response slave id '%v' does not match request '%v'", aduResponse[0], aduRequest[0])
 		return
 	}
 	return
@@ -69,7 +68,7 @@ func (rtu *RTUPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	crc.reset().pushBytes(adu[0 : length-2])
 	checksum := uint16(adu[length-1])<<8 | uint16(adu[length-2])
 	if checksum != crc.value() {
-		err = fmt.Errorf("modbus: response crc '%v' does not match expected '%v'", checksum, crc.value())
+		err = frameErrorf(ErrChecksum, adu, "modbus: response crc '%v' does not match expected '%v'", checksum, crc.value())
 		return
 	}
 	// Function code & data
@@ -79,7 +78,7 @@ func (rtu *RTUPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	return
 }
 
-func (rtu *RTUPackager) transceive(transporter Transporter, logger Logger, aduRequest []byte, timeout time.Duration) (aduResponse []byte, err error) {
+func (rtu *RTUPackager) transceive(transporter Transporter, aduRequest []byte, timeout time.Duration, enron enronRanges) (aduResponse []byte, err error) {
 	// make sure port is connected
 	err = transporter.Connect()
 	if err != nil {
@@ -94,13 +93,12 @@ func (rtu *RTUPackager) transceive(transporter Transporter, logger Logger, aduRe
 	}
 
 	// Send the request
-	log(logger, "modbus: sending % x\n", aduRequest)
 	if _, err = transporter.Write(aduRequest); err != nil {
 		return
 	}
 	function := aduRequest[1]
 	functionFail := aduRequest[1] & 0x80
-	bytesToRead := calculateResponseLength(aduRequest)
+	bytesToRead := calculateResponseLength(aduRequest, enron)
 
 	var n int
 	var n1 int
@@ -134,11 +132,10 @@ func (rtu *RTUPackager) transceive(transporter Transporter, logger Logger, aduRe
 		return
 	}
 	aduResponse = data[:n]
-	log(logger, "modbus: received % x\n", aduResponse)
 	return
 }
 
-func calculateResponseLength(adu []byte) int {
+func calculateResponseLength(adu []byte, enron enronRanges) int {
 	length := rtuMinSize
 	switch adu[1] {
 	case FuncCodeReadDiscreteInputs,
@@ -152,7 +149,12 @@ func calculateResponseLength(adu []byte) int {
 		FuncCodeReadHoldingRegisters,
 		FuncCodeReadWriteMultipleRegisters:
 		count := int(binary.BigEndian.Uint16(adu[4:]))
-		length += 1 + count*2
+		size := 2
+		// 32-bit registers of the Enron extension
+		if wide, _ := enron.lookup(binary.BigEndian.Uint16(adu[2:]), uint16(count)); wide && adu[1] != FuncCodeReadWriteMultipleRegisters {
+			size = 4
+		}
+		length += 1 + count*size
 	case FuncCodeWriteSingleCoil,
 		FuncCodeWriteMultipleCoils,
 		FuncCodeWriteSingleRegister,
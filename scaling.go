@@ -0,0 +1,226 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// RoundMode selects how values written are rounded to integer types.
+type RoundMode byte
+
+const (
+	// RoundNearest rounds half away from zero.
+	RoundNearest RoundMode = iota
+	// RoundDown rounds towards negative infinity.
+	RoundDown
+	// RoundUp rounds towards positive infinity.
+	RoundUp
+	// RoundTowardZero truncates.
+	RoundTowardZero
+)
+
+func (m RoundMode) round(f float64) float64 {
+	switch m {
+	case RoundDown:
+		return math.Floor(f)
+	case RoundUp:
+		return math.Ceil(f)
+	case RoundTowardZero:
+		return math.Trunc(f)
+	}
+	return math.Round(f)
+}
+
+// Scaling converts between a stored number and engineering units:
+//
+//	value = stored * Gain * 10^Exponent + Offset
+//
+// For example, a SunSpec inverter reporting power in W_SF scaled watts:
+//
+//	power := modbus.Scaling{Type: modbus.TypeInt16, ScaleFactor: client.HoldingRegister(40084)}
+//	watts, err := client.HoldingRegister(40083).ReadScaled(power)
+type Scaling struct {
+	// Type is the stored number, uint16 if zero, laid out in Order.
+	Type  NumberType
+	Order WordOrder
+	// Gain is 1 if zero.
+	Gain     float64
+	Exponent int
+	Offset   float64
+	// ScaleFactor, if set, is read before every conversion and overrides
+	// Exponent, as the signed _SF registers of SunSpec models.
+	ScaleFactor InputRegister
+	// Min and Max, if Min < Max, bound the values written. Values out of
+	// range are an error, unless Clamp is set: then they are clamped to
+	// Min and Max and to the range of Type.
+	Min, Max float64
+	Clamp    bool
+	// Round converts values written to integer types.
+	Round RoundMode
+}
+
+// Codec returns a codec applying s with its fixed Exponent; ScaleFactor is
+// not read. Decode returns a float64.
+func (s Scaling) Codec() Codec {
+	return scalingCodec{s, s.Exponent}
+}
+
+// codec returns the codec of s, reading the scale factor if set.
+func (s Scaling) codec() (Codec, error) {
+	if s.ScaleFactor == nil {
+		return s.Codec(), nil
+	}
+	sf, err := s.ScaleFactor.Read()
+	if err != nil {
+		return nil, err
+	}
+	if sf == 0x8000 {
+		return nil, fmt.Errorf("modbus: scale factor is not implemented")
+	}
+	exp := int(int16(sf))
+	if exp < -10 || exp > 10 {
+		return nil, fmt.Errorf("modbus: scale factor '%v' is out of range", exp)
+	}
+	return scalingCodec{s, exp}, nil
+}
+
+type scalingCodec struct {
+	s   Scaling
+	exp int
+}
+
+func (c scalingCodec) number() numberCodec {
+	t := c.s.Type
+	if t == 0 {
+		t = TypeUint16
+	}
+	return numberCodec{t, c.s.Order}
+}
+
+func (c scalingCodec) Words() int {
+	return c.number().Words()
+}
+
+// factor applies gain and exponent to f, or reverts them if inverse.
+// Negative exponents divide to keep decimal fractions exact.
+func (c scalingCodec) factor(f float64, inverse bool) float64 {
+	gain := c.s.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	p := math.Pow10(c.exp)
+	if c.exp < 0 {
+		p = math.Pow10(-c.exp)
+		inverse = !inverse
+	}
+	if inverse {
+		return f / gain / p
+	}
+	return f * gain * p
+}
+
+func (c scalingCodec) Decode(words []uint16) (interface{}, error) {
+	v, err := c.number().Decode(words)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := toNumber(v)
+	return c.factor(n.float(), false) + c.s.Offset, nil
+}
+
+func (c scalingCodec) Encode(v interface{}) ([]uint16, error) {
+	n, ok := toNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("modbus: %T cannot be scaled", v)
+	}
+	f := n.float()
+	if math.IsNaN(f) {
+		return nil, fmt.Errorf("modbus: NaN cannot be scaled")
+	}
+	if s := c.s; s.Min < s.Max && (f < s.Min || f > s.Max) {
+		if !s.Clamp {
+			return nil, fmt.Errorf("modbus: value '%v' is out of range ['%v', '%v']", f, s.Min, s.Max)
+		}
+		f = math.Max(s.Min, math.Min(s.Max, f))
+	}
+	raw := c.factor(f-c.s.Offset, true)
+	number := c.number()
+	if !number.typ.float() {
+		raw = c.s.Round.round(raw)
+		if c.s.Clamp {
+			raw = clampInt(raw, number.typ)
+		}
+	}
+	return number.Encode(raw)
+}
+
+// clampInt limits f to the range of the integer type t.
+func clampInt(f float64, t NumberType) float64 {
+	bits := 16 * t.Words()
+	min, max := 0.0, math.Ldexp(1, bits)-1
+	if t.signed() {
+		min, max = -math.Ldexp(1, bits-1), math.Ldexp(1, bits-1)-1
+	}
+	// The largest 64-bit values are not exact in float64.
+	if bits == 64 {
+		max = math.Nextafter(max, 0)
+	}
+	return math.Max(min, math.Min(max, f))
+}
+
+// readScaled reads and scales the registers of s with read.
+func readScaled(read func(address, quantity uint16) ([]uint16, error), address, count uint16, s Scaling) (value float64, err error) {
+	codec, err := s.codec()
+	if err != nil {
+		return
+	}
+	v, err := readAs(read, address, count, codec)
+	if err != nil {
+		return
+	}
+	return v.(float64), nil
+}
+
+// scaledWords converts value to the registers of s.
+func scaledWords(s Scaling, value float64) (words []uint16, err error) {
+	codec, err := s.codec()
+	if err != nil {
+		return
+	}
+	return codec.Encode(value)
+}
+
+func (io *roRegister) ReadScaled(s Scaling) (float64, error) {
+	return readScaled(io.master.ReadInputRegisters, io.address, 1, s)
+}
+
+func (io *rwRegister) ReadScaled(s Scaling) (float64, error) {
+	return readScaled(io.master.ReadHoldingRegisters, io.address, 1, s)
+}
+
+func (io *rwRegister) WriteScaled(s Scaling, value float64) (err error) {
+	words, err := scaledWords(s, value)
+	if err != nil {
+		return
+	}
+	if len(words) != 1 {
+		return errorf(ErrInvalidQuantity, "modbus: %v registers do not fit in a single register", len(words))
+	}
+	return io.Write(words[0])
+}
+
+func (io *roRegisters) ReadScaled(s Scaling) (float64, error) {
+	return readScaled(io.master.ReadInputRegisters, io.address, io.count, s)
+}
+
+func (io *rwRegisters) ReadScaled(s Scaling) (float64, error) {
+	return readScaled(io.master.ReadHoldingRegisters, io.address, io.count, s)
+}
+
+func (io *rwRegisters) WriteScaled(s Scaling, value float64) (err error) {
+	words, err := scaledWords(s, value)
+	if err != nil {
+		return
+	}
+	return io.Write(words)
+}
@@ -0,0 +1,64 @@
+//go:build go1.21
+// +build go1.21
+
+package modbus
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// slogHandler adapts a log/slog logger.
+type slogHandler struct {
+	logger *slog.Logger
+}
+
+// NewSlogHandler returns a LogHandler writing records to logger. Debug, info
+// and error records map to the slog levels of the same name.
+func NewSlogHandler(logger *slog.Logger) LogHandler {
+	return &slogHandler{logger: logger}
+}
+
+func (h *slogHandler) Enabled(level LogLevel) bool {
+	return h.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+func (h *slogHandler) Handle(r *LogRecord) {
+	attrs := make([]slog.Attr, 0, 10)
+	if r.Direction != "" {
+		attrs = append(attrs, slog.String("direction", r.Direction))
+	}
+	attrs = append(attrs,
+		slog.Int("slave_id", int(r.SlaveID)),
+		slog.Int("function_code", int(r.FunctionCode)))
+	if r.TransactionID != 0 {
+		attrs = append(attrs, slog.Int("transaction_id", int(r.TransactionID)))
+	}
+	if r.Bytes != 0 {
+		attrs = append(attrs, slog.Int("bytes", r.Bytes))
+	}
+	if r.Frame != nil {
+		attrs = append(attrs, slog.String("frame", hex.EncodeToString(r.Frame)))
+	}
+	if r.Duration != 0 {
+		attrs = append(attrs, slog.Duration("duration", r.Duration))
+	}
+	if r.Attempt != 0 {
+		attrs = append(attrs, slog.Int("attempt", r.Attempt))
+	}
+	if r.Err != nil {
+		attrs = append(attrs, slog.Any("error", r.Err))
+	}
+	h.logger.LogAttrs(context.Background(), slogLevel(r.Level), r.Message, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,209 @@
+package modbus
+
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Charset is the character encoding of register-backed text.
+type Charset byte
+
+const (
+	// CharsetBytes passes bytes through unchecked.
+	CharsetBytes Charset = iota
+	CharsetUTF8
+	// CharsetLatin1 is ISO 8859-1, converted to and from UTF-8.
+	CharsetLatin1
+	CharsetASCII
+)
+
+func (c Charset) String() string {
+	switch c {
+	case CharsetBytes:
+		return "bytes"
+	case CharsetUTF8:
+		return "utf-8"
+	case CharsetLatin1:
+		return "latin-1"
+	case CharsetASCII:
+		return "ascii"
+	}
+	return fmt.Sprintf("Charset(%d)", byte(c))
+}
+
+// StringFormat describes how text is stored in registers. The zero value
+// stores two bytes per register, high byte first, padded with NUL.
+type StringFormat struct {
+	// SwapBytes stores the first byte of each pair in the low byte, as
+	// some Schneider and ABB devices do.
+	SwapBytes bool
+	// Padding fills the registers after the text, NUL if zero. Text read
+	// ends at the first NUL; trailing padding is removed.
+	Padding byte
+	// Fixed pads writes to all registers instead of the registers the text
+	// needs.
+	Fixed bool
+	// Truncate cuts text too long for the registers instead of failing.
+	Truncate bool
+	Charset  Charset
+}
+
+// StringCodec returns the codec of text of up to 2*words bytes in format.
+// It always encodes all words, as if format.Fixed were set.
+func StringCodec(words int, format StringFormat) Codec {
+	return stringCodec{words, format}
+}
+
+type stringCodec struct {
+	words  int
+	format StringFormat
+}
+
+func (c stringCodec) Words() int {
+	return c.words
+}
+
+func (c stringCodec) Decode(words []uint16) (interface{}, error) {
+	return c.format.decode(words)
+}
+
+func (c stringCodec) Encode(v interface{}) ([]uint16, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.String {
+		return nil, fmt.Errorf("modbus: %T cannot be encoded as string", v)
+	}
+	f := c.format
+	f.Fixed = true
+	return f.encode(rv.String(), c.words)
+}
+
+// decode converts registers to text.
+func (f StringFormat) decode(words []uint16) (s string, err error) {
+	b := wordsToByteArray(words)
+	if f.SwapBytes {
+		swapPairs(b)
+	}
+	for i, c := range b {
+		if c == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	for len(b) > 0 && b[len(b)-1] == f.Padding {
+		b = b[:len(b)-1]
+	}
+	switch f.Charset {
+	case CharsetUTF8:
+		if !utf8.Valid(b) {
+			err = fmt.Errorf("modbus: string % x is not valid UTF-8", b)
+			return
+		}
+	case CharsetLatin1:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes), nil
+	case CharsetASCII:
+		for _, c := range b {
+			if c >= utf8.RuneSelf {
+				err = fmt.Errorf("modbus: string % x is not ASCII", b)
+				return
+			}
+		}
+	}
+	return string(b), nil
+}
+
+// encode converts text to at most count registers, all of them if Fixed.
+func (f StringFormat) encode(s string, count int) (words []uint16, err error) {
+	var b []byte
+	switch f.Charset {
+	case CharsetUTF8:
+		if !utf8.ValidString(s) {
+			err = fmt.Errorf("modbus: string '%v' is not valid UTF-8", s)
+			return
+		}
+		b = []byte(s)
+		if f.Truncate && len(b) > 2*count {
+			// Do not split a character
+			n := 2 * count
+			for n > 0 && !utf8.RuneStart(b[n]) {
+				n--
+			}
+			b = b[:n]
+		}
+	case CharsetLatin1:
+		for _, r := range s {
+			if r > 0xFF {
+				err = fmt.Errorf("modbus: string '%v' is not Latin-1", s)
+				return
+			}
+			b = append(b, byte(r))
+		}
+	case CharsetASCII:
+		for i := 0; i < len(s); i++ {
+			if s[i] >= utf8.RuneSelf {
+				err = fmt.Errorf("modbus: string '%v' is not ASCII", s)
+				return
+			}
+		}
+		b = []byte(s)
+	default:
+		b = []byte(s)
+	}
+	if len(b) > 2*count {
+		if !f.Truncate {
+			err = errorf(ErrInvalidQuantity, "modbus: string of %v bytes does not fit in %v registers", len(b), count)
+			return
+		}
+		b = b[:2*count]
+	}
+	n := count
+	if !f.Fixed {
+		n = (len(b) + 1) / 2
+	}
+	padded := make([]byte, 2*n)
+	for i := copy(padded, b); i < len(padded); i++ {
+		padded[i] = f.Padding
+	}
+	if f.SwapBytes {
+		swapPairs(padded)
+	}
+	return bytesToWordArray(padded), nil
+}
+
+// swapPairs swaps the bytes of each pair in b.
+func swapPairs(b []byte) {
+	for i := 0; i+1 < len(b); i += 2 {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+}
+
+func (io *roRegisters) ReadStringFormat(format StringFormat) (s string, err error) {
+	words, err := io.Read()
+	if err != nil {
+		return
+	}
+	return format.decode(words)
+}
+
+func (io *rwRegisters) ReadStringFormat(format StringFormat) (s string, err error) {
+	words, err := io.Read()
+	if err != nil {
+		return
+	}
+	return format.decode(words)
+}
+
+func (io *rwRegisters) WriteStringFormat(s string, format StringFormat) (err error) {
+	words, err := format.encode(s, int(io.count))
+	if err != nil {
+		return
+	}
+	if len(words) == 0 {
+		return errorf(ErrInvalidQuantity, "modbus: empty string is written with Fixed only")
+	}
+	return io.Write(words)
+}
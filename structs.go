@@ -0,0 +1,511 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structField is a struct field mapped to bits or registers by its tag.
+type structField struct {
+	index    int
+	name     string
+	table    Table
+	address  uint16
+	count    uint16 // bits or registers
+	codec    Codec  // nil for bit tables
+	readOnly bool
+}
+
+// structPlans caches the fields of struct types, by reflect.Type.
+var structPlans sync.Map
+
+type structPlan struct {
+	fields []*structField
+	err    error
+}
+
+// ReadStruct fills the fields of the struct pointed to by v that carry a
+// modbus tag, reading adjacent fields of a table with one request:
+//
+//	type Meter struct {
+//		Voltage float32  `modbus:"hr,3000,cdab,scale=0.1"`
+//		Serial  string   `modbus:"hr,3010,len=8,ro"`
+//		Alarm   bool     `modbus:"di,12"`
+//		Energy  float64  `modbus:"ir,100,type=uint32,scale=0.01"`
+//		Temp    float32  `modbus:"ir,102,type=int16,scale=0.1,offset=-40"`
+//	}
+//
+// A tag gives the table (hr, ir, coil or di) and address, followed by
+// options: the word order abcd, cdab, badc or dcba; type= the number type
+// stored in the registers if it differs from the field type, or one of bcd,
+// uint48, unixtime, datetime and cp56time2a, times in UTC; scale= and
+// offset= the Gain and Offset of a Scaling; len= the registers of a string,
+// bcd or []uint16 or the bits of a []bool; swap, pad=nul|space,
+// charset=utf8|latin1|ascii and truncate the StringFormat of a string; ro to
+// leave the field out of WriteStruct. v is left unchanged if an error is
+// returned.
+func (c *ClientHandler) ReadStruct(v interface{}) (err error) {
+	rv, fields, err := structFields(v)
+	if err != nil {
+		return
+	}
+	if err = c.enronFields(fields); err != nil {
+		return
+	}
+	result := reflect.New(rv.Type()).Elem()
+	result.Set(rv)
+	for _, table := range []Table{TableCoils, TableDiscreteInputs, TableInputRegisters, TableHoldingRegisters} {
+		members := fieldsOf(fields, table, false)
+		spans := coalesce(len(members), func(i int) (uint16, uint16) {
+			return members[i].address, members[i].count
+		}, 0, table.maxRead(), nil)
+		for _, sp := range spans {
+			var bits []bool
+			var registers []uint16
+			switch table {
+			case TableCoils:
+				bits, err = c.ReadCoils(sp.address, sp.quantity)
+			case TableDiscreteInputs:
+				bits, err = c.ReadDiscreteInputs(sp.address, sp.quantity)
+			case TableInputRegisters:
+				registers, err = c.InputRegisters(sp.address, sp.quantity).Read()
+			case TableHoldingRegisters:
+				registers, err = c.HoldingRegisters(sp.address, sp.quantity).Read()
+			}
+			if err != nil {
+				return
+			}
+			for _, m := range sp.members {
+				f := members[m]
+				i := int(f.address - sp.address)
+				j := i + int(f.count)
+				field := result.Field(f.index)
+				if table.IsBits() {
+					if field.Kind() == reflect.Bool {
+						field.SetBool(bits[i])
+					} else {
+						field.Set(reflect.ValueOf(append([]bool(nil), bits[i:j]...)))
+					}
+					continue
+				}
+				var value interface{}
+				if value, err = f.codec.Decode(registers[i:j]); err != nil {
+					return fmt.Errorf("modbus: field '%v': %v", f.name, err)
+				}
+				if err = setField(field, value); err != nil {
+					return fmt.Errorf("modbus: field '%v': %v", f.name, err)
+				}
+			}
+		}
+	}
+	rv.Set(result)
+	return
+}
+
+// WriteStruct writes the coil and holding register fields of the struct
+// pointed to by v that are not tagged ro, adjacent fields with one request.
+// See ReadStruct for the tags. All fields are encoded before the first
+// write; the writes themselves are not atomic.
+func (c *ClientHandler) WriteStruct(v interface{}) (err error) {
+	rv, fields, err := structFields(v)
+	if err != nil {
+		return
+	}
+	if err = c.enronFields(fields); err != nil {
+		return
+	}
+	type write struct {
+		table   Table
+		address uint16
+		bits    []bool
+		words   []uint16
+	}
+	var writes []write
+	for _, table := range []Table{TableCoils, TableHoldingRegisters} {
+		max := MaxWriteRegisters
+		if table == TableCoils {
+			max = MaxWriteCoils
+		}
+		members := fieldsOf(fields, table, true)
+		spans := coalesce(len(members), func(i int) (uint16, uint16) {
+			return members[i].address, members[i].count
+		}, 0, max, nil)
+		for _, sp := range spans {
+			w := write{table: table, address: sp.address}
+			if table.IsBits() {
+				w.bits = make([]bool, sp.quantity)
+			} else {
+				w.words = make([]uint16, sp.quantity)
+			}
+			for _, m := range sp.members {
+				f := members[m]
+				if int(f.count) > max {
+					return fmt.Errorf("modbus: field '%v' of %v items exceeds a write request", f.name, f.count)
+				}
+				i := int(f.address - sp.address)
+				field := rv.Field(f.index)
+				if table.IsBits() {
+					if field.Kind() == reflect.Bool {
+						w.bits[i] = field.Bool()
+					} else if copy(w.bits[i:i+int(f.count)], field.Interface().([]bool)) != int(f.count) {
+						return fmt.Errorf("modbus: field '%v' must have %v bits", f.name, f.count)
+					}
+					continue
+				}
+				var words []uint16
+				if words, err = f.codec.Encode(field.Interface()); err != nil {
+					return fmt.Errorf("modbus: field '%v': %v", f.name, err)
+				}
+				copy(w.words[i:], words)
+			}
+			writes = append(writes, w)
+		}
+	}
+	for _, w := range writes {
+		if w.table == TableCoils {
+			err = c.WriteMultipleCoils(w.address, w.bits)
+		} else {
+			err = c.HoldingRegisters(w.address, uint16(len(w.words))).Write(w.words)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// fieldsOf returns the fields of table, only the writable ones if write.
+func fieldsOf(fields []*structField, table Table, write bool) (members []*structField) {
+	for _, f := range fields {
+		if f.table == table && !(write && f.readOnly) {
+			members = append(members, f)
+		}
+	}
+	return
+}
+
+// enronFields rejects register fields in the Enron ranges of c, as fields
+// count 16-bit registers.
+func (c *ClientHandler) enronFields(fields []*structField) error {
+	for _, f := range fields {
+		if !f.table.IsBits() && c.enron.overlaps(f.address, f.count) {
+			return fmt.Errorf("modbus: field '%v' is in an Enron range, see ReadEnronRegisters", f.name)
+		}
+	}
+	return nil
+}
+
+// structFields returns the struct v points to and its tagged fields.
+func structFields(v interface{}) (rv reflect.Value, fields []*structField, err error) {
+	rv = reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		err = fmt.Errorf("modbus: %T is not a pointer to a struct", v)
+		return
+	}
+	rv = rv.Elem()
+	if plan, ok := structPlans.Load(rv.Type()); ok {
+		p := plan.(*structPlan)
+		return rv, p.fields, p.err
+	}
+	p := &structPlan{}
+	p.fields, p.err = parseStruct(rv.Type())
+	structPlans.Store(rv.Type(), p)
+	return rv, p.fields, p.err
+}
+
+// parseStruct parses the modbus tags of the fields of t.
+func parseStruct(t reflect.Type) (fields []*structField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("modbus")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("modbus: field '%v' is not exported", sf.Name)
+		}
+		var f *structField
+		if f, err = parseField(sf, tag); err != nil {
+			return nil, fmt.Errorf("modbus: field '%v': %v", sf.Name, err)
+		}
+		f.index = i
+		fields = append(fields, f)
+	}
+	return
+}
+
+func parseField(sf reflect.StructField, tag string) (f *structField, err error) {
+	parts := strings.Split(tag, ",")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("tag '%v' must give table and address", tag)
+	}
+	f = &structField{name: sf.Name}
+	switch strings.TrimSpace(parts[0]) {
+	case "coil":
+		f.table = TableCoils
+	case "di":
+		f.table = TableDiscreteInputs
+		f.readOnly = true
+	case "ir":
+		f.table = TableInputRegisters
+		f.readOnly = true
+	case "hr":
+		f.table = TableHoldingRegisters
+	default:
+		return nil, fmt.Errorf("unknown table '%v'", parts[0])
+	}
+	address, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 0, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%v'", parts[1])
+	}
+	f.address = uint16(address)
+
+	var (
+		order  WordOrder
+		typ    NumberType
+		scale  float64
+		offset float64
+		length int
+		format StringFormat
+		text   bool
+		packed string
+	)
+	for _, opt := range parts[2:] {
+		opt = strings.TrimSpace(opt)
+		name, value := opt, ""
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			name, value = opt[:i], opt[i+1:]
+		}
+		switch name {
+		case "abcd", "cdab", "badc", "dcba":
+			order, _ = ParseWordOrder(name)
+		case "type":
+			switch value {
+			case "bcd", "uint48", "unixtime", "datetime", "cp56time2a":
+				packed = value
+			default:
+				typ, err = ParseNumberType(value)
+			}
+		case "scale":
+			scale, err = strconv.ParseFloat(value, 64)
+			if err == nil && (scale == 0 || math.IsInf(scale, 0) || math.IsNaN(scale)) {
+				err = fmt.Errorf("invalid scale '%v'", value)
+			}
+		case "offset":
+			offset, err = strconv.ParseFloat(value, 64)
+			if err == nil && (math.IsInf(offset, 0) || math.IsNaN(offset)) {
+				err = fmt.Errorf("invalid offset '%v'", value)
+			}
+		case "len":
+			length, err = strconv.Atoi(value)
+			if err == nil && length < 1 {
+				err = fmt.Errorf("invalid len '%v'", value)
+			}
+		case "swap":
+			format.SwapBytes, text = true, true
+		case "pad":
+			switch value {
+			case "nul":
+				format.Padding = 0
+			case "space":
+				format.Padding = ' '
+			default:
+				err = fmt.Errorf("invalid pad '%v'", value)
+			}
+			text = true
+		case "charset":
+			switch value {
+			case "utf8":
+				format.Charset = CharsetUTF8
+			case "latin1":
+				format.Charset = CharsetLatin1
+			case "ascii":
+				format.Charset = CharsetASCII
+			default:
+				err = fmt.Errorf("invalid charset '%v'", value)
+			}
+			text = true
+		case "truncate":
+			format.Truncate, text = true, true
+		case "ro":
+			f.readOnly = true
+		default:
+			err = fmt.Errorf("unknown option '%v'", opt)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	kind := sf.Type.Kind()
+	if text && kind != reflect.String {
+		return nil, fmt.Errorf("swap, pad, charset and truncate apply to string fields only")
+	}
+	if f.table.IsBits() {
+		switch {
+		case kind == reflect.Bool && length == 0:
+			f.count = 1
+		case sf.Type == reflect.TypeOf([]bool(nil)) && length > 0:
+			f.count = uint16(length)
+		default:
+			return nil, fmt.Errorf("%v fields must be bool or []bool with len", f.table)
+		}
+		if typ != 0 || scale != 0 || offset != 0 {
+			return nil, fmt.Errorf("type, scale and offset do not apply to %v", f.table)
+		}
+	} else {
+		switch {
+		case kind == reflect.String:
+			if length == 0 {
+				return nil, fmt.Errorf("string fields need len")
+			}
+			f.codec = StringCodec(length, format)
+		case packed != "":
+			if scale != 0 || offset != 0 {
+				return nil, fmt.Errorf("scale and offset do not apply to type %v", packed)
+			}
+			if f.codec, err = packedCodec(packed, sf.Type, length, order); err != nil {
+				return
+			}
+		case sf.Type == reflect.TypeOf([]uint16(nil)):
+			if length == 0 {
+				return nil, fmt.Errorf("[]uint16 fields need len")
+			}
+			f.codec = RawCodec(length)
+		default:
+			if typ == 0 {
+				if typ = fieldNumberType(kind); typ == 0 {
+					return nil, fmt.Errorf("type of %v field must be given", sf.Type)
+				}
+			}
+			if length != 0 {
+				return nil, fmt.Errorf("len does not apply to %v fields", sf.Type)
+			}
+			f.codec = NumberCodec(typ, order)
+			if scale != 0 || offset != 0 {
+				f.codec = Scaling{Type: typ, Order: order, Gain: scale, Offset: offset}.Codec()
+			}
+		}
+		if f.codec.Words() > MaxReadRegisters {
+			return nil, fmt.Errorf("len '%v' exceeds '%v' registers", length, MaxReadRegisters)
+		}
+		f.count = uint16(f.codec.Words())
+	}
+	if int(f.address)+int(f.count) > 65536 {
+		return nil, fmt.Errorf("%v items at address '%v' exceed the table", f.count, f.address)
+	}
+	return
+}
+
+// packedCodec returns the codec of a field of type t tagged with a packed
+// type: bcd of len registers, 1 if not given, uint48, unixtime, datetime or
+// cp56time2a. Times are in UTC.
+func packedCodec(name string, t reflect.Type, length int, order WordOrder) (Codec, error) {
+	isTime := t == reflect.TypeOf(time.Time{})
+	switch name {
+	case "bcd", "uint48":
+		if fieldNumberType(t.Kind()) == 0 || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			return nil, fmt.Errorf("type %v needs an integer field", name)
+		}
+		if name == "uint48" {
+			if length != 0 {
+				return nil, fmt.Errorf("len does not apply to type %v", name)
+			}
+			return Counter48Codec(order), nil
+		}
+		if length == 0 {
+			length = 1
+		}
+		if length > 4 {
+			return nil, fmt.Errorf("invalid len '%v' of type bcd", length)
+		}
+		return BCDCodec(length, order), nil
+	}
+	if !isTime {
+		return nil, fmt.Errorf("type %v needs a time.Time field", name)
+	}
+	if length != 0 {
+		return nil, fmt.Errorf("len does not apply to type %v", name)
+	}
+	switch name {
+	case "unixtime":
+		return UnixTimeCodec(order), nil
+	case "datetime":
+		return DateTimeCodec(time.UTC), nil
+	}
+	return CP56Time2aCodec(order, time.UTC), nil
+}
+
+// fieldNumberType returns the number type stored for a field kind, zero if
+// it must be given explicitly.
+func fieldNumberType(kind reflect.Kind) NumberType {
+	switch kind {
+	case reflect.Int8, reflect.Int16:
+		return TypeInt16
+	case reflect.Uint8, reflect.Uint16:
+		return TypeUint16
+	case reflect.Int32:
+		return TypeInt32
+	case reflect.Uint32:
+		return TypeUint32
+	case reflect.Int64:
+		return TypeInt64
+	case reflect.Uint64:
+		return TypeUint64
+	case reflect.Float32:
+		return TypeFloat32
+	case reflect.Float64:
+		return TypeFloat64
+	}
+	return 0
+}
+
+// setField assigns a decoded value to a field, converting numbers.
+func setField(field reflect.Value, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return nil
+	}
+	if rv.Kind() == reflect.String && field.Kind() == reflect.String {
+		field.SetString(rv.String())
+		return nil
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%T cannot be assigned to %v", v, field.Type())
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.kind == reflect.Float64 {
+			n.f = math.Round(n.f)
+		}
+		i, ok := n.int(uint(field.Type().Bits()))
+		if !ok {
+			return fmt.Errorf("value '%v' overflows %v", v, field.Type())
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.kind == reflect.Float64 {
+			n.f = math.Round(n.f)
+		}
+		u, ok := n.uint(uint(field.Type().Bits()))
+		if !ok {
+			return fmt.Errorf("value '%v' overflows %v", v, field.Type())
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if field.OverflowFloat(n.float()) {
+			return fmt.Errorf("value '%v' overflows %v", v, field.Type())
+		}
+		field.SetFloat(n.float())
+	default:
+		return fmt.Errorf("%T cannot be assigned to %v", v, field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Table is one of the four Modbus data tables.
+type Table byte
+
+const (
+	TableCoils Table = iota + 1
+	TableDiscreteInputs
+	TableInputRegisters
+	TableHoldingRegisters
+)
+
+func (t Table) String() string {
+	switch t {
+	case TableCoils:
+		return "coils"
+	case TableDiscreteInputs:
+		return "discrete inputs"
+	case TableInputRegisters:
+		return "input registers"
+	case TableHoldingRegisters:
+		return "holding registers"
+	}
+	return fmt.Sprintf("Table(%d)", byte(t))
+}
+
+// IsBits reports whether t holds single bits rather than 16-bit registers.
+func (t Table) IsBits() bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// maxRead returns the largest quantity of one read request on t.
+func (t Table) maxRead() int {
+	if t.IsBits() {
+		return MaxReadBits
+	}
+	return MaxReadRegisters
+}
+
+// readFunctionCode returns the function code reading t.
+func (t Table) readFunctionCode() byte {
+	switch t {
+	case TableCoils:
+		return FuncCodeReadCoils
+	case TableDiscreteInputs:
+		return FuncCodeReadDiscreteInputs
+	case TableInputRegisters:
+		return FuncCodeReadInputRegisters
+	}
+	return FuncCodeReadHoldingRegisters
+}
+
+func (t Table) valid() bool {
+	return t >= TableCoils && t <= TableHoldingRegisters
+}
+
+// readTable reads quantity bits or registers of table from slaveID, which
+// need not be the slave of c.
+func (c *ClientHandler) readTable(slaveID byte, table Table, address, quantity uint16) (bits []bool, registers []uint16, err error) {
+	var r *request
+	switch {
+	case !table.valid():
+		r = invalidRequest(table.readFunctionCode(), "modbus: invalid table '%v'", table)
+	case table.IsBits():
+		r = readBitsRequest(table.readFunctionCode(), address, quantity)
+	default:
+		r = readRegistersRequest(c.enron, table.readFunctionCode(), address, quantity)
+	}
+	res := c.call(&slaveID, r)
+	return res.bits, res.registers, res.err
+}
+
+// span is a range of bits or registers accessed by one request.
+type span struct {
+	address  uint16
+	quantity uint16
+	// members are the indexes of the items within the span.
+	members []int
+}
+
+// coalesce merges n items, each given by its address and quantity, into
+// spans of at most max bits or registers, in address order. Spans leave gaps
+// of at most maxGap unused bits or registers between items, but none
+// reaching into the 32-bit registers of enron.
+func coalesce(n int, item func(i int) (address, quantity uint16), maxGap, max int, enron enronRanges) []span {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, _ := item(order[i])
+		b, _ := item(order[j])
+		return a < b
+	})
+	var spans []span
+	for _, i := range order {
+		address, quantity := item(i)
+		start, end := int(address), int(address)+int(quantity)
+		if n := len(spans); n > 0 {
+			s := &spans[n-1]
+			sStart, sEnd := int(s.address), int(s.address)+int(s.quantity)
+			merged := sEnd
+			if end > merged {
+				merged = end
+			}
+			bridged := start > sEnd && enron.overlaps(uint16(sEnd), uint16(start-sEnd))
+			if start-sEnd <= maxGap && merged-sStart <= max && !bridged {
+				s.quantity = uint16(merged - sStart)
+				s.members = append(s.members, i)
+				continue
+			}
+		}
+		spans = append(spans, span{address: address, quantity: uint16(end - start), members: []int{i}})
+	}
+	return spans
+}
@@ -2,7 +2,6 @@ package modbus
 
 import (
 	"encoding/binary"
-	"fmt"
 	"io"
 	"sync/atomic"
 	"time"
@@ -55,19 +54,19 @@ func (tcp *TCPPackager) Verify(aduRequest []byte, aduResponse []byte) (err error
 	responseVal := binary.BigEndian.Uint16(aduResponse)
 	requestVal := binary.BigEndian.Uint16(aduRequest)
 	if responseVal != requestVal {
-		err = fmt.Errorf("modbus: response transaction id '%v' does not match request '%v'", responseVal, requestVal)
+		err = frameErrorf(ErrTransactionMismatch, aduResponse, "modbus: response transaction id '%v' does not match request '%v'", responseVal, requestVal)
 		return
 	}
 	// Protocol id
 	responseVal = binary.BigEndian.Uint16(aduResponse[2:])
 	requestVal = binary.BigEndian.Uint16(aduRequest[2:])
 	if responseVal != requestVal {
-		err = fmt.Errorf("modbus: response protocol id '%v' does not match request '%v'", responseVal, requestVal)
+		err = frameErrorf(ErrFraming, aduResponse, "modbus: response protocol id '%v' does not match request '%v'", responseVal, requestVal)
 		return
 	}
 	// Unit id (1 byte)
 	if aduResponse[6] != aduRequest[6] {
-		err = fmt.Errorf("modbus: response unit id '%v' does not match request '%v'", aduResponse[6], aduRequest[6])
+		err = frameErrorf(ErrUnitIDMismatch, aduResponse, "modbus: response unit id '%v' does not match request '%v'", aduResponse[6], aduRequest[6])
 		return
 	}
 	return
@@ -83,7 +82,7 @@ func (tcp *TCPPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	length := binary.BigEndian.Uint16(adu[4:])
 	pduLength := len(adu) - tcpHeaderSize
 	if pduLength <= 0 || pduLength != int(length-1) {
-		err = fmt.Errorf("modbus: length in response '%v' does not match pdu data length '%v'", length-1, pduLength)
+		err = frameErrorf(ErrLengthMismatch, adu, "modbus: length in response '%v' does not match pdu data length '%v'", length-1, pduLength)
 		return
 	}
 	pdu = &ProtocolDataUnit{}
@@ -93,7 +92,7 @@ func (tcp *TCPPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	return
 }
 
-func (tcp *TCPPackager) transceive(transporter Transporter, logger Logger, aduRequest []byte, timeout time.Duration) (aduResponse []byte, err error) {
+func (tcp *TCPPackager) transceive(transporter Transporter, aduRequest []byte, timeout time.Duration, enron enronRanges) (aduResponse []byte, err error) {
 	// Establish a new connection if not connected
 	if err = transporter.Connect(); err != nil {
 		return
@@ -107,11 +106,15 @@ func (tcp *TCPPackager) transceive(transporter Transporter, logger Logger, aduRe
 	}
 
 	// Send data
-	log(logger, "modbus: sending % x", aduRequest)
 	if _, err = transporter.Write(aduRequest); err != nil {
 		return
 	}
+	return tcp.readADU(transporter)
+}
 
+// readADU reads one response frame. Frames are delimited by the length in
+// their header, so several requests may be in flight on a connection.
+func (tcp *TCPPackager) readADU(transporter Transporter) (aduResponse []byte, err error) {
 	// Read header first
 	var data [tcpMaxLength]byte
 	if _, err = io.ReadFull(transporter, data[:tcpHeaderSize]); err != nil {
@@ -121,12 +124,12 @@ func (tcp *TCPPackager) transceive(transporter Transporter, logger Logger, aduRe
 	length := int(binary.BigEndian.Uint16(data[4:]))
 	if length <= 0 {
 		transporter.Flush()
-		err = fmt.Errorf("modbus: length in response header '%v' must not be zero", length)
+		err = frameErrorf(ErrFraming, data[:tcpHeaderSize], "modbus: length in response header '%v' must not be zero", length)
 		return
 	}
 	if length > (tcpMaxLength - (tcpHeaderSize - 1)) {
 		transporter.Flush()
-		err = fmt.Errorf("modbus: length in response header '%v' must not greater than '%v'", length, tcpMaxLength-tcpHeaderSize+1)
+		err = frameErrorf(ErrFraming, data[:tcpHeaderSize], "modbus: length in response header '%v' must not greater than '%v'", length, tcpMaxLength-tcpHeaderSize+1)
 		return
 	}
 	// Skip unit id
@@ -135,6 +138,5 @@ func (tcp *TCPPackager) transceive(transporter Transporter, logger Logger, aduRe
 		return
 	}
 	aduResponse = data[:length]
-	log(logger, "modbus: received % x\n", aduResponse)
 	return
 }
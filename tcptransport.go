@@ -60,6 +60,10 @@ func (tcp *tcpConnCategoryPort) Flush() (err error) {
 			break
 		}
 	}
+	if err == nil {
+		// Requests without a timeout do not set a deadline
+		err = tcp.conn.SetReadDeadline(time.Time{})
+	}
 	return
 }
 
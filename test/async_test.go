@@ -0,0 +1,119 @@
+package test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+// burstSlave records the most requests written before a response was read.
+type burstSlave struct {
+	*slave
+	writes, maxBurst int
+}
+
+func (s *burstSlave) Write(adu []byte) (int, error) {
+	s.writes++
+	if s.writes > s.maxBurst {
+		s.maxBurst = s.writes
+	}
+	return s.slave.Write(adu)
+}
+
+func (s *burstSlave) Read(b []byte) (int, error) {
+	s.writes = 0
+	return s.slave.Read(b)
+}
+
+func TestAsync(t *testing.T) {
+	s := &burstSlave{slave: newSlave()}
+	for i := range s.holding[:100] {
+		s.holding[i] = uint16(i)
+	}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithPipelining(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	async := modbus.NewAsync(cli)
+
+	// Hold the worker so that the requests queue up.
+	gate := make(chan struct{})
+	async.WriteSingleCoil(0, true).Then(func(f *modbus.Future) {
+		<-gate
+	})
+	futures := make([]*modbus.Future, 50)
+	for i := range futures {
+		async.SetSlaveID(byte(1 + i%2))
+		futures[i] = async.ReadHoldingRegisters(uint16(i), 2)
+	}
+	completed := make(chan *modbus.Future, 1)
+	last := async.WriteMultipleRegisters(200, []uint16{7, 8})
+	last.Then(func(f *modbus.Future) {
+		completed <- f
+	})
+	close(gate)
+
+	for i, f := range futures {
+		values, err := f.Registers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []uint16{uint16(i), uint16(i + 1)}; !reflect.DeepEqual(expected, values) {
+			t.Fatalf("request %v: expected %v, actual %v", i, expected, values)
+		}
+	}
+	if err = (<-completed).Err(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(8), s.holding[201])
+	if s.maxBurst < 2 || s.maxBurst > 8 {
+		t.Errorf("expected up to 8 requests in flight, actual %v", s.maxBurst)
+	}
+
+	// Errors carry the slave of the request.
+	s.exceptions[modbus.FuncCodeReadCoils] = modbus.ExceptionCodeIllegalDataAddress
+	if _, err = async.SetSlaveID(5).ReadCoils(0, 1).Bits(); err == nil {
+		t.Fatal("expected exception")
+	}
+	var e *modbus.Error
+	if err = async.ReadCoils(0, 0).Err(); !errors.As(err, &e) || e.Kind != modbus.ErrInvalidQuantity {
+		t.Fatalf("expected invalid quantity, actual %v", err)
+	}
+	assertEquals(t, byte(5), e.SlaveID)
+
+	// Callbacks of completed requests run right away.
+	var called bool
+	last.Then(func(f *modbus.Future) {
+		called = true
+	})
+	assertEquals(t, true, called)
+
+	if err = async.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = async.WriteSingleRegister(0, 1).Err(); err == nil {
+		t.Fatal("expected error after close")
+	}
+}
+
+func TestAsyncSerial(t *testing.T) {
+	port := &echoPort{}
+	cli, err := modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	async := modbus.NewAsync(cli)
+	defer async.Close()
+	futures := make([]*modbus.Future, 10)
+	for i := range futures {
+		futures[i] = async.WriteSingleRegister(uint16(i), uint16(i))
+	}
+	for _, f := range futures {
+		if err = f.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEquals(t, 10, len(port.writes))
+}
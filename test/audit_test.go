@@ -0,0 +1,166 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+type auditRecorder struct {
+	records []modbus.AuditRecord
+}
+
+func (a *auditRecorder) Audit(r *modbus.AuditRecord) error {
+	a.records = append(a.records, *r)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	s := newSlave()
+	a := &auditRecorder{}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(4),
+		modbus.WithAudit(modbus.Audit{Sink: a, Actor: "commissioning", ReadPrevious: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.holding[10] = 7
+	if err = cli.WriteMultipleRegisters(10, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	cli.SetAuditActor("operator")
+	if err = cli.WriteMultipleCoils(3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	s.exceptions[modbus.FuncCodeMaskWriteRegister] = modbus.ExceptionCodeIllegalFunction
+	if err = cli.MaskWriteRegister(10, 0xFF00, 0x0001); err == nil {
+		t.Fatal("expected exception")
+	}
+
+	assertEquals(t, 3, len(a.records))
+	r := a.records[0]
+	assertEquals(t, "commissioning", r.Actor)
+	assertEquals(t, "tcp", r.Transport)
+	assertEquals(t, byte(4), r.SlaveID)
+	assertEquals(t, byte(modbus.FuncCodeWriteMultipleRegisters), r.FunctionCode)
+	assertEquals(t, uint16(10), r.Address)
+	assertEquals(t, uint16(2), r.Quantity)
+	assertEquals(t, true, reflect.DeepEqual([]uint16{1, 2}, r.Registers))
+	assertEquals(t, true, reflect.DeepEqual([]uint16{7, 0}, r.PreviousRegisters))
+	assertEquals(t, modbus.AuditSuccess, r.Outcome)
+
+	r = a.records[1]
+	assertEquals(t, "operator", r.Actor)
+	assertEquals(t, true, reflect.DeepEqual([]bool{true, false, true}, r.Bits))
+	assertEquals(t, true, reflect.DeepEqual([]bool{false, false, false}, r.PreviousBits))
+
+	r = a.records[2]
+	assertEquals(t, modbus.AuditException, r.Outcome)
+	assertEquals(t, true, reflect.DeepEqual([]uint16{0xFF00, 0x0001}, r.Registers))
+	if r.Err == "" {
+		t.Fatal("expected error message")
+	}
+}
+
+func TestAuditFailedChecks(t *testing.T) {
+	s := newSlave()
+	a := &auditRecorder{}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(4),
+		modbus.WithAudit(modbus.Audit{Sink: a}), modbus.WithWriteVerify(modbus.WriteVerify{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.badEcho = true
+	if err = cli.WriteSingleRegister(10, 1); !errors.Is(err, modbus.ErrEchoMismatch) {
+		t.Fatalf("expected echo mismatch, actual %v", err)
+	}
+	s.badEcho = false
+	s.clamp = 100
+	var verr *modbus.WriteVerifyError
+	if err = cli.WriteSingleRegister(10, 200); !errors.As(err, &verr) {
+		t.Fatalf("expected verify error, actual %v", err)
+	}
+
+	// Both writes were acknowledged, yet failed.
+	assertEquals(t, 2, len(a.records))
+	for _, r := range a.records {
+		assertEquals(t, modbus.AuditError, r.Outcome)
+		if r.Err == "" {
+			t.Error("expected error message")
+		}
+	}
+	assertEquals(t, err.Error(), a.records[1].Err)
+}
+
+func TestAuditEnron(t *testing.T) {
+	s := newSlave()
+	s.enron = 7001
+	s.longs = []uint32{0x00010002}
+	a := &auditRecorder{}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithAudit(modbus.Audit{Sink: a, ReadPrevious: true}),
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 7001, End: 7999}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(7001, 5); err != nil {
+		t.Fatal(err)
+	}
+	// The previous value is read as a 32-bit register.
+	assertEquals(t, 1, len(a.records))
+	assertEquals(t, true, reflect.DeepEqual([]uint16{1, 2}, a.records[0].PreviousRegisters))
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+	sink, err := modbus.OpenJSONLinesSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, newSlave(), modbus.WithAudit(modbus.Audit{Sink: sink}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(1, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleCoil(2, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []modbus.AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r modbus.AuditRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	assertEquals(t, 2, len(records))
+	assertEquals(t, byte(modbus.FuncCodeWriteSingleRegister), records[0].FunctionCode)
+	assertEquals(t, uint16(5), records[0].Registers[0])
+	assertEquals(t, true, records[1].Bits[0])
+}
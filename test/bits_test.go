@@ -0,0 +1,82 @@
+package test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestRegisterBits(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := cli.HoldingRegister(7)
+	s.holding[7] = 0x00F0
+
+	if err = reg.Bit(0).Set(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x00F1), s.holding[7])
+	if err = reg.Bit(4).Toggle(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x00E1), s.holding[7])
+	on, err := reg.Bit(5).Test()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, on)
+
+	mode := reg.Field(8, 3)
+	if err = mode.Write(5); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x05E1), s.holding[7])
+	v, err := mode.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(5), v)
+	assertEquals(t, 3, s.requests[modbus.FuncCodeMaskWriteRegister])
+	if err = mode.Write(8); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity for value wider than the field, got %v", err)
+	}
+	if _, err = reg.Field(12, 5).Read(); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, got %v", err)
+	}
+}
+
+func TestRegisterBitsFallback(t *testing.T) {
+	s := newSlave()
+	s.exceptions[modbus.FuncCodeMaskWriteRegister] = modbus.ExceptionCodeIllegalFunction
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent writers of different bits must not clobber each other.
+	var wg sync.WaitGroup
+	for n := uint(0); n < 16; n++ {
+		wg.Add(1)
+		go func(n uint) {
+			defer wg.Done()
+			if err := cli.HoldingRegister(3).Bit(n).Set(); err != nil {
+				t.Error(err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	assertEquals(t, uint16(0xFFFF), s.holding[3])
+	assertEquals(t, 16, s.requests[modbus.FuncCodeWriteSingleRegister])
+
+	s.exceptions[modbus.FuncCodeMaskWriteRegister] = modbus.ExceptionCodeIllegalDataAddress
+	var me *modbus.ModbusError
+	if err = cli.HoldingRegister(3).Bit(0).Clear(); !errors.As(err, &me) {
+		t.Fatalf("expected exception, got %v", err)
+	}
+	assertEquals(t, uint16(0xFFFF), s.holding[3])
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestBus(t *testing.T) {
+	const gap = 10 * time.Millisecond
+	port := &echoPort{}
+	bus, err := modbus.NewBus(&modbus.RTUPackager{}, port, modbus.WithSerialTiming(modbus.SerialTiming{Gap: gap}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := make([]*modbus.ClientHandler, 3)
+	for i := range clients {
+		if clients[i], err = bus.Client(byte(i+1), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for i, cli := range clients {
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func(cli *modbus.ClientHandler, value uint16) {
+				defer wg.Done()
+				if err := cli.WriteSingleRegister(1, value); err != nil {
+					t.Error(err)
+				}
+			}(cli, uint16(i))
+		}
+	}
+	wg.Wait()
+
+	// The gap applies across clients.
+	assertEquals(t, 9, len(port.writes))
+	for i := 1; i < len(port.writes); i++ {
+		if d := port.writes[i].Sub(port.writes[i-1]); d < gap {
+			t.Errorf("delay %v: expected at least %v, actual %v", i, gap, d)
+		}
+	}
+	stats := bus.Stats()
+	assertEquals(t, uint64(9), stats.Requests)
+	assertEquals(t, 0, stats.Queued)
+
+	// Closing a client leaves the bus usable.
+	if err = clients[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = clients[1].WriteSingleRegister(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = modbus.NewBus(&modbus.TCPPackager{}, port); err == nil {
+		t.Fatal("expected error for Modbus TCP bus")
+	}
+}
+
+func TestBusPriority(t *testing.T) {
+	const gap = 20 * time.Millisecond
+	port := &echoPort{}
+	bus, err := modbus.NewBus(&modbus.RTUPackager{}, port, modbus.WithSerialTiming(modbus.SerialTiming{Gap: gap}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := bus.Client(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := bus.Client(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first write holds the bus for the gap while the others queue.
+	if err = low.WriteSingleRegister(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []byte
+	)
+	write := func(cli *modbus.ClientHandler, slaveID byte) {
+		defer wg.Done()
+		if err := cli.WriteSingleRegister(1, 1); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		order = append(order, slaveID)
+		mu.Unlock()
+	}
+	wg.Add(1)
+	go write(low, 1)
+	time.Sleep(gap / 4)
+	wg.Add(1)
+	go write(low, 1)
+	time.Sleep(gap / 4)
+	wg.Add(1)
+	go write(high, 2)
+	wg.Wait()
+
+	// The first queued request got the bus right away, the high priority one
+	// overtook the second.
+	assertEquals(t, 3, len(order))
+	assertEquals(t, byte(2), order[1])
+	if stats := bus.Stats(); stats.MaxWait <= 0 {
+		t.Errorf("expected requests to wait, actual %+v", stats)
+	}
+}
+
+func TestBusEnron(t *testing.T) {
+	rtu := &modbus.RTUPackager{}
+	port := &trickle{}
+	bus, err := modbus.NewBus(rtu, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enron, err := bus.Client(1, 0, modbus.WithEnronRanges(modbus.EnronRange{Start: 5000, End: 5099}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := bus.Client(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ranges of one client do not apply to the others on the line.
+	response, err := rtu.Encode(2, &modbus.ProtocolDataUnit{
+		FunctionCode: modbus.FuncCodeReadHoldingRegisters,
+		Data:         []byte{4, 0x01, 0x02, 0x03, 0x04},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port.Buffer.Write(response)
+	results, err := plain.ReadHoldingRegisters(5000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, len(results))
+	assertEquals(t, uint16(0x0102), results[0])
+	assertEquals(t, uint16(0x0304), results[1])
+
+	response, err = rtu.Encode(1, &modbus.ProtocolDataUnit{
+		FunctionCode: modbus.FuncCodeReadHoldingRegisters,
+		Data:         []byte{8, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port.Buffer.Write(response)
+	values, err := enron.ReadEnronRegisters(5000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, len(values))
+	assertEquals(t, uint32(0x01020304), values[0])
+	assertEquals(t, uint32(0x05060708), values[1])
+}
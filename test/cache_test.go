@@ -0,0 +1,157 @@
+package test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+// slowSlave answers after a delay.
+type slowSlave struct {
+	*slave
+	delay time.Duration
+}
+
+func (s *slowSlave) Write(adu []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.slave.Write(adu)
+}
+
+func (s *slave) count(function byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[function]
+}
+
+func TestCache(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	s := newSlave()
+	s.holding[10], s.holding[11] = 1, 2
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, &slowSlave{s, 20 * time.Millisecond}, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := modbus.NewCache(cli, ttl)
+
+	// Concurrent reads share one request.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values, err := cache.ReadHoldingRegisters(10, 2)
+			if err != nil {
+				t.Error(err)
+			} else if !reflect.DeepEqual([]uint16{1, 2}, values) {
+				t.Errorf("expected [1 2], actual %v", values)
+			}
+		}()
+	}
+	wg.Wait()
+	assertEquals(t, 1, s.count(modbus.FuncCodeReadHoldingRegisters))
+	assertEquals(t, modbus.CacheStats{Shared: 4, Misses: 1, Entries: 1}, cache.Stats())
+
+	// Cached until an overlapping write; other writes keep it.
+	values, _ := cache.ReadHoldingRegisters(10, 2)
+	values[0] = 99
+	if err = cache.WriteSingleRegister(12, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.WriteSingleCoil(10, true); err != nil {
+		t.Fatal(err)
+	}
+	if values, err = cache.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, s.count(modbus.FuncCodeReadHoldingRegisters))
+	if !reflect.DeepEqual([]uint16{1, 2}, values) {
+		t.Fatalf("expected [1 2], actual %v", values)
+	}
+	if err = cache.WriteMultipleRegisters(11, []uint16{5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if values, err = cache.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, s.count(modbus.FuncCodeReadHoldingRegisters))
+	if !reflect.DeepEqual([]uint16{1, 5}, values) {
+		t.Fatalf("expected [1 5], actual %v", values)
+	}
+
+	// Other slaves and tables are cached apart, results expire.
+	cache.SetSlaveID(2)
+	if _, err = cache.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cache.ReadInputRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 3, s.count(modbus.FuncCodeReadHoldingRegisters))
+	time.Sleep(ttl)
+	if _, err = cache.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 4, s.count(modbus.FuncCodeReadHoldingRegisters))
+
+	// Failed reads are not cached.
+	s.exceptions[modbus.FuncCodeReadCoils] = modbus.ExceptionCodeIllegalDataAddress
+	for i := 0; i < 2; i++ {
+		if _, err = cache.ReadCoils(0, 1); err == nil {
+			t.Fatal("expected exception")
+		}
+	}
+	assertEquals(t, 2, s.count(modbus.FuncCodeReadCoils))
+}
+
+func TestCacheReadModifyWrite(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := modbus.NewCache(cli, time.Hour)
+	if _, err = cache.ReadCoils(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cache.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Changed by another master while cached
+	s.coils[0], s.holding[0] = true, 1
+
+	// Toggles start from the values of the device.
+	if err = cache.Coil(0).Toggle(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, false, s.coils[0])
+	if err = cache.HoldingRegister(0).Bit(0).Toggle(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0), s.holding[0])
+	// The writes dropped the cached values.
+	assertEquals(t, 0, cache.Stats().Entries)
+}
+
+func TestCacheSweep(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, newSlave(), modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := modbus.NewCache(cli, ttl)
+	for address := uint16(0); address < 3; address++ {
+		if _, err = cache.ReadHoldingRegisters(address, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEquals(t, 3, cache.Stats().Entries)
+	time.Sleep(ttl)
+	// Expired results are dropped when the next one is stored.
+	if _, err = cache.ReadHoldingRegisters(10, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, cache.Stats().Entries)
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestDial(t *testing.T) {
+	cli, err := modbus.Dial("tcp://127.0.0.1?unit=3&timeout=250ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, byte(3), cli.SlaveID)
+	assertEquals(t, 250*time.Millisecond, cli.Timeout)
+	if _, ok := cli.Packager.(*modbus.TCPPackager); !ok {
+		t.Fatalf("unexpected packager %T", cli.Packager)
+	}
+
+	cli, err = modbus.Dial("rtu:///dev/ttyUSB0?baud=9600&parity=even&stopbits=2", modbus.WithUnitID(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, byte(7), cli.SlaveID)
+	if _, ok := cli.Packager.(*modbus.RTUPackager); !ok {
+		t.Fatalf("unexpected packager %T", cli.Packager)
+	}
+}
+
+func TestDialInvalid(t *testing.T) {
+	for _, rawurl := range []string{
+		"udp://127.0.0.1",
+		"tcp://?unit=1",
+		"tcp://127.0.0.1?unit=256",
+		"tcp://127.0.0.1?timeout=5",
+		"tcp://127.0.0.1?baud=9600",
+		"rtu://",
+		"rtu:///dev/ttyUSB0?parity=X",
+		"rtu:///dev/ttyUSB0?databits=9",
+		"ascii:///dev/ttyUSB0?stopbits=3",
+		"ascii:///dev/ttyUSB0?connect_timeout=1s",
+	} {
+		if _, err := modbus.Dial(rawurl); err == nil {
+			t.Errorf("%v: expected error", rawurl)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestEnronRegisters(t *testing.T) {
+	s := newSlave()
+	s.enron = 7001
+	s.longs = []uint32{math.Float32bits(1.5), math.Float32bits(-20)}
+	s.holding[100] = 0x1234
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 5001, End: 5999}, modbus.EnronRange{Start: 7001, End: 7999}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	floats, err := cli.ReadEnronFloats(7001, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]float32{1.5, -20}, floats))
+	words, err := cli.ReadHoldingRegisters(7002, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{0xC1A0, 0}, words))
+	if words, err = cli.ReadHoldingRegisters(100, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x1234), words[0])
+
+	if _, err = cli.ReadHoldingRegisters(4990, 20); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity across ranges, actual %v", err)
+	}
+	if _, err = cli.ReadEnronRegisters(7001, 63); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, actual %v", err)
+	}
+	if _, err = cli.ReadEnronRegisters(100, 1); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected error outside Enron ranges, actual %v", err)
+	}
+	// A device answering 16-bit registers in an Enron range
+	s.longs = nil
+	if _, err = cli.ReadEnronRegisters(7001, 2); !errors.Is(err, modbus.ErrLengthMismatch) {
+		t.Fatalf("expected length mismatch, actual %v", err)
+	}
+
+	if _, err = modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 7001, End: 7999}, modbus.EnronRange{Start: 5001, End: 5999})); err == nil {
+		t.Fatal("expected error for unordered ranges")
+	}
+}
+
+func TestEnronLarge(t *testing.T) {
+	s := newSlave()
+	s.enron = 7001
+	for i := 0; i < 100; i++ {
+		s.longs = append(s.longs, uint32(i)<<16|uint32(i))
+	}
+	s.holding[6999], s.holding[7000] = 1, 2
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 7001, End: 7999}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 16-bit registers, then 62 and 8 32-bit ones.
+	words, err := cli.ReadHoldingRegistersLarge(6999, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 3, s.count(modbus.FuncCodeReadHoldingRegisters))
+	assertEquals(t, 2+2*70, len(words))
+	assertEquals(t, true, reflect.DeepEqual([]uint16{1, 2, 0, 0, 1, 1}, words[:6]))
+	assertEquals(t, true, reflect.DeepEqual([]uint16{69, 69}, words[len(words)-2:]))
+
+	// The cache returns the words of ReadHoldingRegisters.
+	cache := modbus.NewCache(cli, time.Second)
+	if words, err = cache.ReadHoldingRegisters(7002, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{1, 1, 2, 2}, words))
+}
+
+func TestEnronTables(t *testing.T) {
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, newSlave(),
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 7001, End: 7010}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := modbus.NewPoller(cli)
+	p.MaxGap = 20
+	if err = p.Add(modbus.Point{Name: "flow", Table: modbus.TableHoldingRegisters, Address: 7005, Rate: time.Hour}); err == nil {
+		t.Fatal("expected error for a point in an Enron range")
+	}
+	err = p.Add(
+		modbus.Point{Name: "a", Table: modbus.TableHoldingRegisters, Address: 7000, Rate: time.Hour},
+		modbus.Point{Name: "b", Table: modbus.TableHoldingRegisters, Address: 7011, Rate: time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The gap between the points is an Enron range.
+	assertEquals(t, 2, p.Requests())
+
+	var v struct {
+		Flow uint32 `modbus:"hr,7001"`
+	}
+	if err = cli.ReadStruct(&v); err == nil {
+		t.Fatal("expected error for a field in an Enron range")
+	}
+}
+
+// trickle is a serial port answering with a fixed frame, a few bytes per
+// read as a slow line would.
+type trickle struct {
+	bytes.Buffer
+}
+
+func (p *trickle) Connect() error                             { return nil }
+func (p *trickle) Close() error                               { return nil }
+func (p *trickle) Flush() error                               { return nil }
+func (p *trickle) SetReadTimeout(timeout time.Duration) error { return nil }
+
+func (p *trickle) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (p *trickle) Read(b []byte) (int, error) {
+	if len(b) > 4 {
+		b = b[:4]
+	}
+	return p.Buffer.Read(b)
+}
+
+func TestEnronRTU(t *testing.T) {
+	rtu := &modbus.RTUPackager{}
+	response, err := rtu.Encode(1, &modbus.ProtocolDataUnit{
+		FunctionCode: modbus.FuncCodeReadHoldingRegisters,
+		Data:         []byte{8, 0, 0, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := &trickle{}
+	port.Buffer.Write(response)
+	cli, err := modbus.NewClientHandler(rtu, port, modbus.WithUnitID(1),
+		modbus.WithEnronRanges(modbus.EnronRange{Start: 5001, End: 5999}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := cli.ReadEnronRegisters(5001, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint32{0x0102, 0x03040506}, values))
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestErrorKinds(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cli.ReadHoldingRegisters(0, 126)
+	if !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, got %v", err)
+	}
+	var e *modbus.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *modbus.Error, got %T", err)
+	}
+	assertEquals(t, byte(3), e.SlaveID)
+	assertEquals(t, byte(modbus.FuncCodeReadHoldingRegisters), e.FunctionCode)
+
+	s.drop = 1
+	_, err = cli.ReadCoils(0, 1)
+	if !errors.Is(err, modbus.ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	var timeout interface{ Timeout() bool }
+	if !errors.As(err, &timeout) || !timeout.Timeout() {
+		t.Fatalf("expected net.Error compatible timeout, got %v", err)
+	}
+
+	s.exceptions[modbus.FuncCodeReadCoils] = modbus.ExceptionCodeIllegalDataAddress
+	_, err = cli.ReadCoils(0, 1)
+	var me *modbus.ModbusError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected exception, got %v", err)
+	}
+	assertEquals(t, byte(modbus.ExceptionCodeIllegalDataAddress), me.ExceptionCode)
+}
+
+func TestErrorChecksum(t *testing.T) {
+	rtu := &modbus.RTUPackager{}
+	adu, err := rtu.Encode(1, &modbus.ProtocolDataUnit{FunctionCode: 3, Data: []byte{2, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adu[len(adu)-1] ^= 0xFF
+	_, err = rtu.Decode(adu)
+	if !errors.Is(err, modbus.ErrChecksum) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	ascii := &modbus.ASCIIPackager{}
+	request, _ := ascii.Encode(1, &modbus.ProtocolDataUnit{FunctionCode: 3, Data: []byte{0, 0, 0, 1}})
+	response, _ := ascii.Encode(2, &modbus.ProtocolDataUnit{FunctionCode: 3, Data: []byte{2, 0, 1}})
+	if err = ascii.Verify(request, response); !errors.Is(err, modbus.ErrUnitIDMismatch) {
+		t.Fatalf("expected unit id mismatch, got %v", err)
+	}
+}
+
+func TestErrorConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Drop the connection without answering
+			io.ReadFull(conn, make([]byte, 12))
+			conn.Close()
+		}
+	}()
+	address := l.Addr().String()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, modbus.NewTCPAddrTransport(address, time.Second),
+		modbus.WithUnitID(5), modbus.WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	_, err = cli.ReadCoils(0, 1)
+	var e *modbus.Error
+	if !errors.As(err, &e) || !errors.Is(err, modbus.ErrConnection) {
+		t.Fatalf("expected connection error, got %T %v", err, err)
+	}
+	assertEquals(t, byte(5), e.SlaveID)
+	assertEquals(t, byte(modbus.FuncCodeReadCoils), e.FunctionCode)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the error of the transporter, got %v", e.Err)
+	}
+
+	// Nothing listens any more.
+	l.Close()
+	cli.Close()
+	if _, err = cli.ReadCoils(0, 1); !errors.Is(err, modbus.ErrConnection) {
+		t.Fatalf("expected connection error, got %v", err)
+	}
+}
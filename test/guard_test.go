@@ -0,0 +1,200 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestGuard(t *testing.T) {
+	s := newSlave()
+	h := &recordingHandler{level: modbus.LogLevelInfo}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(1), modbus.WithLogHandler(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := modbus.NewGuard(cli, modbus.GuardPolicy{Allow: []modbus.GuardRange{
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 100, End: 109, Min: -50, Max: 50, Signed: true},
+		{SlaveID: 1, Table: modbus.TableCoils, Start: 0, End: 7},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client modbus.Client = g
+
+	if err = client.WriteSingleRegister(100, uint16(0xFFF6)); err != nil { // -10
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0xFFF6), s.holding[100])
+	if err = client.WriteMultipleRegisters(108, []uint16{1, 2, 3}); !errors.Is(err, modbus.ErrWriteDenied) {
+		t.Fatalf("expected write denied beyond the range, actual %v", err)
+	}
+	if err = client.WriteSingleRegister(101, 51); !errors.Is(err, modbus.ErrWriteDenied) {
+		t.Fatalf("expected write denied above max, actual %v", err)
+	}
+	if err = client.HoldingRegister(5).Write(1); !errors.Is(err, modbus.ErrWriteDenied) {
+		t.Fatalf("expected write denied outside ranges, actual %v", err)
+	}
+	if err = client.MaskWriteRegister(100, 0x00FF, 0x7F00); !errors.Is(err, modbus.ErrWriteDenied) {
+		t.Fatalf("expected mask write denied above max, actual %v", err)
+	}
+	if err = client.Coil(7).Set(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, s.coils[7])
+	client.SetSlaveID(2)
+	if err = client.WriteSingleCoil(7, false); !errors.Is(err, modbus.ErrWriteDenied) {
+		t.Fatalf("expected write denied to other slave, actual %v", err)
+	}
+	assertEquals(t, 0, s.requests[modbus.FuncCodeWriteMultipleRegisters])
+	assertEquals(t, 1, s.requests[modbus.FuncCodeWriteSingleRegister])
+
+	client.SetSlaveID(1)
+	dry, err := modbus.NewGuard(cli, modbus.GuardPolicy{DryRun: true, Allow: []modbus.GuardRange{
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 0, End: 9},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.records = nil
+	if err = dry.WriteSingleRegister(3, 7); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0), s.holding[3])
+	assertEquals(t, 1, s.requests[modbus.FuncCodeWriteSingleRegister])
+	assertEquals(t, 1, len(h.records))
+	assertEquals(t, modbus.DirectionDryRun, h.records[0].Direction)
+	assertEquals(t, 12, len(h.records[0].Frame))
+
+	if _, err = modbus.NewGuard(cli, modbus.GuardPolicy{Allow: []modbus.GuardRange{{Table: modbus.TableInputRegisters}}}); err == nil {
+		t.Fatal("expected error for input register range")
+	}
+}
+
+// switcher changes the slave of cli when it receives a read, as a
+// concurrent SetSlaveID would during a Guard read-modify-write.
+type switcher struct {
+	*slave
+	cli *modbus.ClientHandler
+}
+
+func (s *switcher) Write(adu []byte) (int, error) {
+	if adu[7] == modbus.FuncCodeReadHoldingRegisters {
+		// The client is locked while it sends.
+		s.cli.SlaveID = 2
+	}
+	return s.slave.Write(adu)
+}
+
+func TestGuardSlaveChange(t *testing.T) {
+	s := &switcher{slave: newSlave()}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cli = cli
+	g, err := modbus.NewGuard(cli, modbus.GuardPolicy{Allow: []modbus.GuardRange{
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 0, End: 9, Min: 0, Max: 100},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The register is read to check the value it would take.
+	if err = g.MaskWriteRegister(0, 0xFF00, 0x0001); err != nil {
+		t.Fatal(err)
+	}
+	// The write allowed for slave 1 went to slave 1.
+	assertEquals(t, 1, s.count(modbus.FuncCodeWriteSingleRegister))
+	assertEquals(t, 0, s.units[2])
+
+	// A dry run logs the request for slave 1.
+	h := &recordingHandler{level: modbus.LogLevelInfo}
+	cli.SetLogHandler(h)
+	cli.SlaveID = 1
+	dry, err := modbus.NewGuard(cli, modbus.GuardPolicy{DryRun: true, Allow: []modbus.GuardRange{
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 0, End: 9, Min: 0, Max: 100},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dry.MaskWriteRegister(0, 0xFF00, 0x0002); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(h.records))
+	assertEquals(t, modbus.DirectionDryRun, h.records[0].Direction)
+	assertEquals(t, byte(1), h.records[0].SlaveID)
+}
+
+// meddler changes a holding register after the slave answers a read of it,
+// as another writer would during a Guard read-modify-write.
+type meddler struct {
+	*slave
+	address uint16
+	value   uint16
+}
+
+func (s *meddler) Write(adu []byte) (int, error) {
+	n, err := s.slave.Write(adu)
+	if adu[7] == modbus.FuncCodeReadHoldingRegisters {
+		s.mu.Lock()
+		s.holding[s.address] = s.value
+		s.mu.Unlock()
+	}
+	return n, err
+}
+
+func TestGuardMaskWrite(t *testing.T) {
+	s := &meddler{slave: newSlave(), address: 0, value: 0x0100}
+	s.holding[0] = 0x0010
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := modbus.NewGuard(cli, modbus.GuardPolicy{Allow: []modbus.GuardRange{
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 0, End: 9, Min: 0, Max: 100},
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 10, End: 19},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The value checked is the value written, whatever changed meanwhile.
+	if err = g.MaskWriteRegister(0, 0xFF00, 0x0005); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x0005), s.holding[0])
+	assertEquals(t, 0, s.count(modbus.FuncCodeMaskWriteRegister))
+
+	// Bit writes hold the read-modify-write lock already.
+	if err = g.HoldingRegister(0).Bit(1).Set(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x0007), s.holding[0])
+
+	// Ranges without limits use Mask Write Register.
+	if err = g.MaskWriteRegister(10, 0xFF00, 0x0005); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, s.count(modbus.FuncCodeMaskWriteRegister))
+}
+
+func TestGuardOptions(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	allow := []modbus.GuardRange{{SlaveID: 1, Table: modbus.TableHoldingRegisters, Start: 0, End: 9}}
+	// A dry run without log output would show nothing.
+	if _, err = modbus.NewGuard(cli, modbus.GuardPolicy{DryRun: true, Allow: allow}); err == nil {
+		t.Fatal("expected error for a dry run without logger")
+	}
+	g, err := modbus.NewGuard(cli, modbus.GuardPolicy{Allow: allow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.ReadWriteMultipleRegisters(0, 1, 0, 2, []byte{0, 1, 0}); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity for a short value, actual %v", err)
+	}
+	assertEquals(t, 0, s.count(modbus.FuncCodeReadWriteMultipleRegisters))
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestLargeRequests(t *testing.T) {
+	for _, depth := range []int{1, 4} {
+		s := newSlave()
+		cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithPipelining(depth))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values := make([]uint16, 300)
+		for i := range values {
+			values[i] = uint16(i * 3)
+		}
+		if err = cli.WriteMultipleRegistersLarge(100, values); err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, 3, s.requests[modbus.FuncCodeWriteMultipleRegisters])
+		read, err := cli.ReadHoldingRegistersLarge(100, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, 3, s.requests[modbus.FuncCodeReadHoldingRegisters])
+		if !reflect.DeepEqual(values, read) {
+			t.Fatalf("depth %d: registers read back differ", depth)
+		}
+
+		coils := make([]bool, 4500)
+		for i := range coils {
+			coils[i] = i%3 == 0
+		}
+		if err = cli.WriteMultipleCoilsLarge(7, coils); err != nil {
+			t.Fatal(err)
+		}
+		readCoils, err := cli.ReadCoilsLarge(7, 4500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, 3, s.requests[modbus.FuncCodeWriteMultipleCoils])
+		assertEquals(t, 3, s.requests[modbus.FuncCodeReadCoils])
+		if !reflect.DeepEqual(coils, readCoils) {
+			t.Fatalf("depth %d: coils read back differ", depth)
+		}
+	}
+}
+
+func TestLargeRequestsChunkError(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithPipelining(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The response to the second chunk arrives, the first one never does.
+	s.drop = 1
+	_, err = cli.ReadInputRegistersLarge(0, 250)
+	var chunkErr *modbus.ChunkError
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("expected chunk error, got %v", err)
+	}
+	assertEquals(t, 0, chunkErr.Index)
+	assertEquals(t, uint16(125), chunkErr.Quantity)
+	if !errors.Is(err, modbus.ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	// Failed pipelined writes are not sent again.
+	if cli, err = modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithPipelining(3), modbus.WithRetry(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	s.drop = 1
+	err = cli.WriteMultipleRegistersLarge(0, make([]uint16, 250))
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("expected chunk error, got %v", err)
+	}
+	assertEquals(t, 0, chunkErr.Index)
+	assertEquals(t, 3, s.count(modbus.FuncCodeWriteMultipleRegisters))
+	// Reads are.
+	s.drop = 1
+	if _, err = cli.ReadInputRegistersLarge(0, 250); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = cli.ReadInputRegistersLarge(65500, 100); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, got %v", err)
+	}
+
+	if _, err = modbus.NewClientHandler(&modbus.RTUPackager{}, s, modbus.WithPipelining(2)); err == nil {
+		t.Fatal("expected error for pipelining on RTU")
+	}
+}
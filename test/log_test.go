@@ -0,0 +1,78 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+type recordingHandler struct {
+	level   modbus.LogLevel
+	records []modbus.LogRecord
+}
+
+func (h *recordingHandler) Enabled(level modbus.LogLevel) bool {
+	return level >= h.level
+}
+
+func (h *recordingHandler) Handle(r *modbus.LogRecord) {
+	h.records = append(h.records, *r)
+}
+
+func TestLogHandler(t *testing.T) {
+	h := &recordingHandler{}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, newSlave(),
+		modbus.WithUnitID(5), modbus.WithLogHandler(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, len(h.records))
+	send, receive := h.records[0], h.records[1]
+	assertEquals(t, modbus.DirectionSend, send.Direction)
+	assertEquals(t, modbus.DirectionReceive, receive.Direction)
+	assertEquals(t, byte(5), send.SlaveID)
+	assertEquals(t, byte(modbus.FuncCodeReadHoldingRegisters), send.FunctionCode)
+	assertEquals(t, uint16(1), send.TransactionID)
+	assertEquals(t, 12, send.Bytes)
+	assertEquals(t, 13, receive.Bytes)
+	if send.Frame != nil || receive.Frame != nil {
+		t.Fatal("frames logged without frame logging")
+	}
+
+	cli.SetFrameLogging(true)
+	h.records = nil
+	if _, err = cli.ReadHoldingRegisters(10, 2); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 12, len(h.records[0].Frame))
+}
+
+func TestLogHandlerErrors(t *testing.T) {
+	s := newSlave()
+	s.drop = 1
+	s.exceptions[modbus.FuncCodeWriteSingleRegister] = modbus.ExceptionCodeIllegalDataAddress
+	h := &recordingHandler{level: modbus.LogLevelInfo}
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithRetry(1, time.Millisecond), modbus.WithLogHandler(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.ReadCoils(0, 8); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(h.records))
+	assertEquals(t, modbus.LogLevelInfo, h.records[0].Level)
+	assertEquals(t, 1, h.records[0].Attempt)
+
+	h.records = nil
+	if err = cli.WriteSingleRegister(0, 1); err == nil {
+		t.Fatal("expected exception")
+	}
+	assertEquals(t, 1, len(h.records))
+	assertEquals(t, modbus.LogLevelError, h.records[0].Level)
+	assertEquals(t, err, h.records[0].Err)
+}
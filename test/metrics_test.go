@@ -0,0 +1,86 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	s := newSlave()
+	s.drop = 1
+	s.exceptions[modbus.FuncCodeWriteSingleCoil] = modbus.ExceptionCodeServerDeviceBusy
+	metrics := modbus.NewPrometheusMetrics()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s,
+		modbus.WithUnitID(9), modbus.WithRetry(1, 0), modbus.WithReconnect(true), modbus.WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleCoil(0, true); err == nil {
+		t.Fatal("expected exception")
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`modbus_requests_total{transport="tcp",endpoint="",slave="9",function="3"} 1`,
+		`modbus_requests_total{transport="tcp",endpoint="",slave="9",function="5"} 1`,
+		`modbus_request_failures_total{transport="tcp",endpoint="",slave="9",function="5"} 1`,
+		`modbus_timeouts_total{transport="tcp",endpoint="",slave="9",function="3"} 1`,
+		`modbus_exceptions_total{transport="tcp",endpoint="",slave="9",function="5",code="6"} 1`,
+		`modbus_reconnects_total{transport="tcp",endpoint=""} 1`,
+		`modbus_request_duration_seconds_count{transport="tcp",endpoint="",slave="9",function="3"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusMetricsEchoMismatch(t *testing.T) {
+	s := newSlave()
+	s.badEcho = true
+	metrics := modbus.NewPrometheusMetrics()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithUnitID(9), modbus.WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(0, 1); !errors.Is(err, modbus.ErrEchoMismatch) {
+		t.Fatalf("expected echo mismatch, actual %v", err)
+	}
+
+	// The request is counted as failed although the slave answered.
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	line := `modbus_request_failures_total{transport="tcp",endpoint="",slave="9",function="6"} 1`
+	if !strings.Contains(body, line+"\n") {
+		t.Errorf("missing %q in:\n%s", line, body)
+	}
+}
+
+func TestPrometheusMetricsBuckets(t *testing.T) {
+	metrics := modbus.NewPrometheusMetrics(1, 0.1)
+	labels := modbus.MetricLabels{Transport: "rtu", Endpoint: "/dev/ttyS0", SlaveID: 1, FunctionCode: 3}
+	metrics.ObserveRequest(labels, 50*time.Millisecond, nil)
+	metrics.ObserveRequest(labels, 500*time.Millisecond, nil)
+	metrics.ObserveRequest(labels, 5*time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	prefix := `modbus_request_duration_seconds_bucket{transport="rtu",endpoint="/dev/ttyS0",slave="1",function="3",`
+	for _, line := range []string{prefix + `le="0.1"} 1`, prefix + `le="1"} 2`, prefix + `le="+Inf"} 3`} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
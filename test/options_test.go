@@ -0,0 +1,105 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestNewClientHandler(t *testing.T) {
+	cli, err := modbus.NewClientHandler(&modbus.RTUPackager{},
+		modbus.NewSerialTransport("/dev/ttyUSB0", 9600, 8, "E", 1, time.Second),
+		modbus.WithUnitID(4), modbus.WithTimeout(time.Second), modbus.WithRetry(2, 10*time.Millisecond),
+		modbus.WithReconnect(true), modbus.WithInterFrameDelay(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, byte(4), cli.SlaveID)
+	assertEquals(t, time.Second, cli.Timeout)
+}
+
+func TestNewClientHandlerInvalid(t *testing.T) {
+	serial := modbus.NewSerialTransport("/dev/ttyUSB0", 9600, 8, "E", 1, time.Second)
+	tcp := modbus.NewTCPAddrTransport("127.0.0.1:502", time.Second)
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	cases := []struct {
+		name        string
+		packager    modbus.Packager
+		transporter modbus.Transporter
+		opts        []modbus.Option
+	}{
+		{"nil transporter", &modbus.TCPPackager{}, nil, nil},
+		{"negative timeout", &modbus.TCPPackager{}, tcp, []modbus.Option{modbus.WithTimeout(-1)}},
+		{"negative retries", &modbus.TCPPackager{}, tcp, []modbus.Option{modbus.WithRetry(-1, 0)}},
+		{"inter-frame delay on tcp", &modbus.TCPPackager{}, tcp, []modbus.Option{modbus.WithInterFrameDelay(time.Millisecond)}},
+		{"connect timeout on serial", &modbus.RTUPackager{}, serial, []modbus.Option{modbus.WithConnectTimeout(time.Second)}},
+		{"reconnect on existing conn", &modbus.TCPPackager{}, modbus.NewTCPConnTransport(conn), []modbus.Option{modbus.WithReconnect(true)}},
+	}
+	for _, c := range cases {
+		if _, err := modbus.NewClientHandler(c.packager, c.transporter, c.opts...); err == nil {
+			t.Errorf("%v: expected error", c.name)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	s := newSlave()
+	s.drop = 2
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithRetry(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 3, s.count(modbus.FuncCodeWriteSingleRegister))
+
+	// Retries are exhausted.
+	s.drop = 3
+	if err = cli.WriteSingleRegister(1, 2); !errors.Is(err, modbus.ErrTimeout) {
+		t.Fatalf("expected timeout, actual %v", err)
+	}
+	assertEquals(t, 6, s.count(modbus.FuncCodeWriteSingleRegister))
+}
+
+func TestReconnect(t *testing.T) {
+	s := newSlave()
+	s.drop = 1
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithRetry(1, 0), modbus.WithReconnect(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The failed attempt closed the transporter, the retry connected again.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assertEquals(t, 1, s.closes)
+	assertEquals(t, 2, s.connects)
+}
+
+func TestInterFrameDelay(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	port := &echoPort{}
+	cli, err := modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithUnitID(1), modbus.WithInterFrameDelay(delay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = cli.WriteSingleRegister(1, uint16(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEquals(t, 3, len(port.writes))
+	for i := 1; i < len(port.writes); i++ {
+		if actual := port.writes[i].Sub(port.writes[i-1]); actual < delay {
+			t.Errorf("delay %v: expected at least %v, actual %v", i, delay, actual)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestPackedCodecs(t *testing.T) {
+	bcd := modbus.BCDCodec(2, modbus.OrderABCD)
+	v, err := bcd.Decode([]uint16{0x1234, 0x5678})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint64(12345678), v)
+	words, err := bcd.Encode(907)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{0x0000, 0x0907}, words))
+	if _, err = bcd.Decode([]uint16{0x00A0, 0}); err == nil {
+		t.Fatal("expected invalid BCD digit")
+	}
+	if _, err = bcd.Encode(100000000); err == nil {
+		t.Fatal("expected BCD overflow")
+	}
+
+	counter := modbus.Counter48Codec(modbus.OrderCDAB)
+	if words, err = counter.Encode(uint64(0x123456789ABC)); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{0x9ABC, 0x5678, 0x1234}, words))
+	if v, err = counter.Decode(words); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint64(0x123456789ABC), v)
+	if _, err = counter.Encode(uint64(1) << 48); err == nil {
+		t.Fatal("expected 48-bit overflow")
+	}
+
+	stamp := time.Date(2024, time.March, 5, 13, 45, 30, 250*int(time.Millisecond), time.UTC)
+	unix := modbus.UnixTimeCodec(modbus.OrderABCD)
+	if words, err = unix.Encode(stamp); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = unix.Decode(words); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, stamp.Truncate(time.Second), v)
+
+	dt := modbus.DateTimeCodec(nil)
+	if words, err = dt.Encode(stamp); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{2024, 3, 5, 13, 45, 30}, words))
+	if _, err = dt.Decode([]uint16{2024, 2, 30, 0, 0, 0}); err == nil {
+		t.Fatal("expected invalid date")
+	}
+
+	cp56 := modbus.CP56Time2aCodec(modbus.OrderBADC, nil)
+	if words, err = cp56.Encode(stamp); err != nil {
+		t.Fatal(err)
+	}
+	// 30250 ms, 45 min, 13 h, Tuesday the 5th, March, 2024
+	assertEquals(t, true, reflect.DeepEqual([]uint16{30250, 0x0D2D, 0x0345, 0x0018}, words))
+	if v, err = cp56.Decode(words); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, stamp, v)
+	words[1] |= 0x0080
+	if _, err = cp56.Decode(words); err == nil {
+		t.Fatal("expected invalid time stamp")
+	}
+}
+
+func TestStructPacked(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Energy uint64    `modbus:"hr,0,type=uint48"`
+		Serial uint32    `modbus:"hr,3,type=bcd,len=2"`
+		Clock  time.Time `modbus:"hr,5,type=datetime"`
+	}
+	v.Energy = 1 << 40
+	v.Serial = 20240305
+	v.Clock = time.Date(2024, time.March, 5, 13, 45, 30, 0, time.UTC)
+	if err = cli.WriteStruct(&v); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, s.requests[modbus.FuncCodeWriteMultipleRegisters])
+	assertEquals(t, uint16(0x0100), s.holding[0])
+	assertEquals(t, uint16(0x2024), s.holding[3])
+	assertEquals(t, uint16(2024), s.holding[5])
+
+	want := v
+	v.Energy, v.Serial, v.Clock = 0, 0, time.Time{}
+	if err = cli.ReadStruct(&v); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, want, v)
+
+	var bad struct {
+		Clock uint32 `modbus:"hr,0,type=unixtime"`
+	}
+	if err = cli.ReadStruct(&bad); err == nil {
+		t.Fatal("expected error for unixtime on integer field")
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestPoller(t *testing.T) {
+	s := newSlave()
+	s.holding[10], s.holding[11], s.holding[14] = 1, 2, 3
+	s.coils[1999] = true
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := modbus.NewPoller(cli)
+	p.MaxGap = 2
+	err = p.Add(
+		modbus.Point{Name: "a", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 10, Quantity: 2, Rate: time.Hour},
+		modbus.Point{Name: "b", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 14, Rate: time.Hour},
+		// Gap too large
+		modbus.Point{Name: "c", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 20, Rate: time.Hour},
+		// Other slave
+		modbus.Point{Name: "d", SlaveID: 2, Table: modbus.TableHoldingRegisters, Address: 12, Rate: time.Hour},
+		// Beyond the 2000 bits of one request
+		modbus.Point{Name: "e", SlaveID: 1, Table: modbus.TableCoils, Address: 0, Rate: time.Hour},
+		modbus.Point{Name: "f", SlaveID: 1, Table: modbus.TableCoils, Address: 1999, Rate: time.Hour},
+		modbus.Point{Name: "g", SlaveID: 1, Table: modbus.TableCoils, Address: 2000, Rate: time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 5, p.Requests())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	samples := make(map[string]modbus.Sample)
+	p.OnSample = func(sample modbus.Sample) {
+		mu.Lock()
+		defer mu.Unlock()
+		samples[sample.Point.Name] = sample
+		if len(samples) == 7 {
+			cancel()
+		}
+	}
+	if err = p.Run(ctx); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	assertEquals(t, 3, s.requests[modbus.FuncCodeReadHoldingRegisters])
+	assertEquals(t, 2, s.requests[modbus.FuncCodeReadCoils])
+	assertEquals(t, 2, len(samples["a"].Registers))
+	assertEquals(t, uint16(2), samples["a"].Registers[1])
+	assertEquals(t, uint16(3), samples["b"].Registers[0])
+	assertEquals(t, true, samples["f"].Bits[0])
+	assertEquals(t, false, samples["g"].Bits[0])
+}
+
+func TestPollerDeadlineMiss(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := modbus.NewPoller(cli)
+	if err = p.Add(modbus.Point{Table: modbus.TableInputRegisters, Rate: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Add(modbus.Point{Table: modbus.TableInputRegisters, Quantity: 126, Rate: time.Second}); err == nil {
+		t.Fatal("expected error for quantity above 125")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var miss modbus.DeadlineMiss
+	p.OnDeadlineMiss = func(m modbus.DeadlineMiss) {
+		miss = m
+		cancel()
+	}
+	if err = p.Run(ctx); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	assertEquals(t, modbus.TableInputRegisters, miss.Table)
+	if miss.Skipped < 1 || miss.Late <= 0 {
+		t.Fatalf("unexpected deadline miss %+v", miss)
+	}
+}
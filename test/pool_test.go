@@ -0,0 +1,151 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+// echoServer is a Modbus TCP server answering Write Single Register
+// requests by echoing them. It closes connections after closeAfter
+// requests, if not zero.
+type echoServer struct {
+	listener   net.Listener
+	closeAfter int
+
+	mu       sync.Mutex
+	accepted int
+}
+
+func newEchoServer(t *testing.T, closeAfter int) *echoServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &echoServer{listener: l, closeAfter: closeAfter}
+	go s.serve()
+	return s
+}
+
+func (s *echoServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.accepted++
+		s.mu.Unlock()
+		go func() {
+			defer conn.Close()
+			adu := make([]byte, 12)
+			for n := 1; ; n++ {
+				if _, err := io.ReadFull(conn, adu); err != nil {
+					return
+				}
+				if _, err := conn.Write(adu); err != nil || n == s.closeAfter {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (s *echoServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+func (s *echoServer) url(unit int) string {
+	return fmt.Sprintf("tcp://%v?unit=%v", s.listener.Addr(), unit)
+}
+
+func TestTCPPool(t *testing.T) {
+	s := newEchoServer(t, 0)
+	defer s.listener.Close()
+	pool := &modbus.TCPPool{MaxConns: 2, IdleTimeout: 100 * time.Millisecond}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for unit := 1; unit <= 3; unit++ {
+		cli, err := modbus.Dial(s.url(unit), modbus.WithTCPPool(pool))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(value uint16) {
+				defer wg.Done()
+				if err := cli.WriteSingleRegister(1, value); err != nil {
+					t.Error(err)
+				}
+			}(uint16(i))
+		}
+	}
+	wg.Wait()
+
+	address := s.listener.Addr().String()
+	stats := pool.Stats(address)
+	if stats.Open < 1 || stats.Open > 2 || stats.InUse != 0 {
+		t.Errorf("expected 1 or 2 idle connections, actual %+v", stats)
+	}
+	if n := s.connections(); n > 2 {
+		t.Errorf("expected at most 2 connections, actual %v", n)
+	}
+
+	assertEquals(t, modbus.PoolStats{}, pool.Stats("127.0.0.1:1"))
+
+	// Idle connections are evicted.
+	time.Sleep(300 * time.Millisecond)
+	assertEquals(t, modbus.PoolStats{}, pool.Stats(address))
+
+	if _, err := modbus.NewClientHandler(&modbus.TCPPackager{}, newSlave(), modbus.WithTCPPool(pool)); err == nil {
+		t.Fatal("expected error for pool without TCP address transport")
+	}
+}
+
+func TestTCPPoolHealthCheck(t *testing.T) {
+	s := newEchoServer(t, 1)
+	defer s.listener.Close()
+	pool := &modbus.TCPPool{}
+	defer pool.Close()
+	cli, err := modbus.Dial(s.url(1), modbus.WithTCPPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = cli.WriteSingleRegister(1, uint16(i)); err != nil {
+			t.Fatal(err)
+		}
+		// Let the server close the connection
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Connections closed by the server are not reused.
+	assertEquals(t, 3, s.connections())
+}
+
+func TestTCPPoolWithoutTimeout(t *testing.T) {
+	s := newEchoServer(t, 0)
+	defer s.listener.Close()
+	pool := &modbus.TCPPool{}
+	defer pool.Close()
+	// Unlike Dial, NewClientHandler sets no response timeout.
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{},
+		modbus.NewTCPAddrTransport(s.listener.Addr().String(), 0), modbus.WithTCPPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = cli.WriteSingleRegister(1, uint16(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The connection passed the health check each time.
+	assertEquals(t, 1, s.connections())
+}
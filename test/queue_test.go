@@ -0,0 +1,119 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestQueueDeadline(t *testing.T) {
+	const gap = 60 * time.Millisecond
+	port := &echoPort{}
+	metrics := modbus.NewPrometheusMetrics()
+	cli, err := modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithUnitID(1),
+		modbus.WithSerialTiming(modbus.SerialTiming{Gap: gap}), modbus.WithMaxWait(gap/3), modbus.WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The second request holds the turn while it waits for the gap, the
+	// third is dropped.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := cli.WriteSingleRegister(1, 2); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(gap / 6)
+	err = cli.WriteSingleRegister(1, 3)
+	wg.Wait()
+	if !errors.Is(err, modbus.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, actual %v", err)
+	}
+	var e *modbus.Error
+	if errors.As(err, &e) {
+		assertEquals(t, byte(1), e.SlaveID)
+		assertEquals(t, byte(modbus.FuncCodeWriteSingleRegister), e.FunctionCode)
+	}
+	assertEquals(t, 2, len(port.writes))
+
+	stats := cli.QueueStats()
+	assertEquals(t, uint64(2), stats.Requests)
+	assertEquals(t, uint64(1), stats.Dropped)
+	assertEquals(t, 0, stats.Queued)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`modbus_queue_dropped_total{transport="rtu",endpoint="",slave="1",function="6"} 1`,
+		`modbus_queue_depth{transport="rtu",endpoint=""} 0`,
+		`modbus_queue_wait_seconds_count{transport="rtu",endpoint="",slave="1",function="6"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+
+	if _, err = modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithMaxWait(0)); err == nil {
+		t.Fatal("expected error for zero maximum wait")
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	const gap = 40 * time.Millisecond
+	port := &echoPort{}
+	bus, err := modbus.NewBus(&modbus.RTUPackager{}, port, modbus.WithSerialTiming(modbus.SerialTiming{Gap: gap}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Slave 1 has no deadline, slave 2 a late and slave 3 an early one.
+	clients := make(map[byte]*modbus.ClientHandler)
+	for slaveID, opt := range map[byte]modbus.Option{
+		1: modbus.WithPriority(0),
+		2: modbus.WithMaxWait(10 * gap),
+		3: modbus.WithMaxWait(5 * gap),
+	} {
+		if clients[slaveID], err = bus.Client(slaveID, 0, opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = clients[1].WriteSingleRegister(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []byte
+	)
+	for _, slaveID := range []byte{1, 1, 2, 3} {
+		wg.Add(1)
+		go func(cli *modbus.ClientHandler, slaveID byte) {
+			defer wg.Done()
+			if err := cli.WriteSingleRegister(1, 1); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			order = append(order, slaveID)
+			mu.Unlock()
+		}(clients[slaveID], slaveID)
+		time.Sleep(gap / 8)
+	}
+	wg.Wait()
+
+	// The first request got the turn right away, then the earliest deadline
+	// goes first and requests without one last.
+	assertEquals(t, 4, len(order))
+	assertEquals(t, byte(3), order[1])
+	assertEquals(t, byte(2), order[2])
+	assertEquals(t, byte(1), order[3])
+}
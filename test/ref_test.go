@@ -0,0 +1,85 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		s         string
+		zeroBased bool
+		ref       modbus.Ref
+	}{
+		{"40001", false, modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 0}},
+		{"40108", false, modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 107}},
+		{"40108", true, modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 108}},
+		{"465536", false, modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 65535}},
+		{"300009", false, modbus.Ref{Table: modbus.TableInputRegisters, Address: 8}},
+		{"3x0009", false, modbus.Ref{Table: modbus.TableInputRegisters, Address: 8}},
+		{"1X12", false, modbus.Ref{Table: modbus.TableDiscreteInputs, Address: 11}},
+		{"00001", false, modbus.Ref{Table: modbus.TableCoils, Address: 0}},
+		{"%MW100", false, modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 100}},
+		{"%iw3", false, modbus.Ref{Table: modbus.TableInputRegisters, Address: 3}},
+		{"%M7", false, modbus.Ref{Table: modbus.TableCoils, Address: 7}},
+		{"%I0", false, modbus.Ref{Table: modbus.TableDiscreteInputs, Address: 0}},
+	}
+	for _, tt := range tests {
+		ref, err := modbus.ParseRef(tt.s, tt.zeroBased)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.s, err)
+		}
+		if ref != tt.ref {
+			t.Errorf("%v: expected %+v, actual %+v", tt.s, tt.ref, ref)
+		}
+	}
+	for _, s := range []string{"40000", "465537", "4x0", "4x65537", "20001", "108", "4000001", "%MW", "%MW-1", "4x+1"} {
+		if ref, err := modbus.ParseRef(s, false); err == nil {
+			t.Errorf("%v: expected error, actual %+v", s, ref)
+		}
+	}
+	assertEquals(t, "400108", modbus.Ref{Table: modbus.TableHoldingRegisters, Address: 107}.String())
+}
+
+func TestReadWriteRef(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Write("40108", uint16(42)); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(42), s.holding[107])
+	assertEquals(t, 1, s.requests[modbus.FuncCodeWriteSingleRegister])
+	if err = cli.Write("4x0109", []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	_, registers, err := cli.Read("400108", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{42, 1, 2}, registers))
+	if err = cli.Write("00003", true); err != nil {
+		t.Fatal(err)
+	}
+	bits, _, err := cli.Read("%M2", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, bits[0])
+	if err = cli.Write("30001", uint16(1)); err == nil {
+		t.Fatal("expected error writing input register")
+	}
+
+	zero, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithZeroBasedRefs(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, registers, err = zero.Read("40107", 1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(42), registers[0])
+}
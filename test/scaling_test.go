@@ -0,0 +1,78 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestScaling(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SunSpec style: power in int16 with a scale factor register of -2
+	s.holding[83] = 0xFF9C // -100
+	s.holding[84] = 0xFFFE
+	power := modbus.Scaling{Type: modbus.TypeInt16, ScaleFactor: cli.HoldingRegister(84)}
+	v, err := cli.HoldingRegister(83).ReadScaled(power)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, -1.0, v)
+	if err = cli.HoldingRegister(83).WriteScaled(power, 12.345); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(1235), s.holding[83])
+
+	s.holding[84] = 0x8000
+	if _, err = cli.HoldingRegister(83).ReadScaled(power); err == nil {
+		t.Fatal("expected error for unimplemented scale factor")
+	}
+
+	// Temperature with gain and offset in an input register
+	s.registers[5] = 650
+	temp := modbus.Scaling{Gain: 0.1, Offset: -40}
+	v, err = cli.InputRegister(5).ReadScaled(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 25.0, v)
+
+	// Limits and rounding
+	setpoint := modbus.Scaling{Exponent: -1, Min: 0, Max: 100, Round: modbus.RoundDown}
+	if err = cli.HoldingRegister(1).WriteScaled(setpoint, 101); err == nil {
+		t.Fatal("expected out of range error")
+	}
+	if err = cli.HoldingRegister(1).WriteScaled(setpoint, 12.39); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(123), s.holding[1])
+	setpoint.Clamp = true
+	if err = cli.HoldingRegister(1).WriteScaled(setpoint, 250); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(1000), s.holding[1])
+	if err = cli.HoldingRegister(1).WriteScaled(modbus.Scaling{Clamp: true}, -5); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0), s.holding[1])
+
+	// Multi-register values
+	energy := modbus.Scaling{Type: modbus.TypeUint32, Order: modbus.OrderCDAB, Exponent: 3}
+	if err = cli.HoldingRegisters(10, 2).WriteScaled(energy, 70000000); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(70000&0xFFFF), s.holding[10])
+	assertEquals(t, uint16(1), s.holding[11])
+	v, err = cli.HoldingRegisters(10, 2).ReadScaled(energy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 7e7, v)
+	if err = cli.HoldingRegister(10).WriteScaled(energy, 1); err == nil {
+		t.Fatal("expected error writing two registers to one")
+	}
+}
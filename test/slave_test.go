@@ -0,0 +1,188 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// slaveTimeoutErr is returned by slave.Read when no response is pending.
+type slaveTimeoutErr struct{}
+
+func (slaveTimeoutErr) Error() string   { return "read slave: i/o timeout" }
+func (slaveTimeoutErr) Timeout() bool   { return true }
+func (slaveTimeoutErr) Temporary() bool { return true }
+
+// slave is an in-memory Modbus TCP device implementing modbus.Transporter.
+type slave struct {
+	mu        sync.Mutex
+	coils     [65536]bool
+	inputs    [65536]bool
+	holding   [65536]uint16
+	registers [65536]uint16 // input registers
+	// longs are 32-bit holding registers from address enron on.
+	enron int
+	longs []uint32
+
+	// exceptions maps function codes to the exception code answered.
+	exceptions map[byte]byte
+	// clamp, if set, silently limits the holding registers written.
+	clamp uint16
+	// drop is the number of requests left unanswered.
+	drop int
+	// badEcho makes write responses echo a different value.
+	badEcho bool
+	// requests counts the requests received per function code, units per
+	// unit id.
+	requests map[byte]int
+	units    map[byte]int
+	connects int
+	closes   int
+
+	response bytes.Buffer
+}
+
+func newSlave() *slave {
+	return &slave{
+		exceptions: make(map[byte]byte),
+		requests:   make(map[byte]int),
+		units:      make(map[byte]int),
+	}
+}
+
+func (s *slave) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connects++
+	return nil
+}
+
+func (s *slave) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+	s.response.Reset()
+	return nil
+}
+
+func (s *slave) SetReadTimeout(timeout time.Duration) error { return nil }
+
+func (s *slave) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.response.Reset()
+	return nil
+}
+
+func (s *slave) Read(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.response.Len() == 0 {
+		return 0, slaveTimeoutErr{}
+	}
+	return s.response.Read(b)
+}
+
+func (s *slave) Write(adu []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	function := adu[7]
+	s.requests[function]++
+	s.units[adu[6]]++
+	if s.drop > 0 {
+		s.drop--
+		return len(adu), nil
+	}
+	var pdu []byte
+	if code, ok := s.exceptions[function]; ok {
+		pdu = []byte{function | 0x80, code}
+	} else {
+		pdu = s.handle(function, adu[8:])
+		if s.badEcho && (function == 5 || function == 6 || function == 15 || function == 16) {
+			pdu[4] ^= 1
+		}
+	}
+	header := make([]byte, 7)
+	copy(header, adu[:4])
+	binary.BigEndian.PutUint16(header[4:], uint16(len(pdu)+1))
+	header[6] = adu[6]
+	s.response.Write(header)
+	s.response.Write(pdu)
+	return len(adu), nil
+}
+
+func (s *slave) handle(function byte, data []byte) []byte {
+	address := int(binary.BigEndian.Uint16(data))
+	value := binary.BigEndian.Uint16(data[2:])
+	switch function {
+	case 1, 2:
+		bits := s.coils[:]
+		if function == 2 {
+			bits = s.inputs[:]
+		}
+		packed := make([]byte, (int(value)+7)/8)
+		for i := 0; i < int(value); i++ {
+			if bits[address+i] {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		return append([]byte{function, byte(len(packed))}, packed...)
+	case 3, 4:
+		words := s.holding[:]
+		if function == 4 {
+			words = s.registers[:]
+		} else if s.longs != nil && address >= s.enron && address < s.enron+len(s.longs) {
+			b := make([]byte, 4*int(value))
+			for i := range s.longs[address-s.enron : address-s.enron+int(value)] {
+				binary.BigEndian.PutUint32(b[4*i:], s.longs[address-s.enron+i])
+			}
+			return append([]byte{function, byte(len(b))}, b...)
+		}
+		return append([]byte{function, byte(2 * value)}, encodeWords(words[address:address+int(value)])...)
+	case 5:
+		s.coils[address] = value == 0xFF00
+		return append([]byte{function}, data[:4]...)
+	case 6:
+		s.holding[address] = s.clamped(value)
+		return append([]byte{function}, data[:4]...)
+	case 15:
+		for i := 0; i < int(value); i++ {
+			s.coils[address+i] = data[5+i/8]&(1<<uint(i%8)) != 0
+		}
+		return append([]byte{function}, data[:4]...)
+	case 16:
+		for i := 0; i < int(value); i++ {
+			s.holding[address+i] = s.clamped(binary.BigEndian.Uint16(data[5+2*i:]))
+		}
+		return append([]byte{function}, data[:4]...)
+	case 22:
+		andMask := binary.BigEndian.Uint16(data[2:])
+		orMask := binary.BigEndian.Uint16(data[4:])
+		s.holding[address] = (s.holding[address] & andMask) | (orMask &^ andMask)
+		return append([]byte{function}, data[:6]...)
+	case 23:
+		writeAddress := int(binary.BigEndian.Uint16(data[4:]))
+		writeQuantity := int(binary.BigEndian.Uint16(data[6:]))
+		for i := 0; i < writeQuantity; i++ {
+			s.holding[writeAddress+i] = binary.BigEndian.Uint16(data[9+2*i:])
+		}
+		return append([]byte{function, byte(2 * value)}, encodeWords(s.holding[address:address+int(value)])...)
+	}
+	return []byte{function | 0x80, 1}
+}
+
+func (s *slave) clamped(value uint16) uint16 {
+	if s.clamp != 0 && value > s.clamp {
+		return s.clamp
+	}
+	return value
+}
+
+func encodeWords(words []uint16) []byte {
+	b := make([]byte, 2*len(words))
+	for i, w := range words {
+		binary.BigEndian.PutUint16(b[2*i:], w)
+	}
+	return b
+}
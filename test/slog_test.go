@@ -0,0 +1,81 @@
+//go:build go1.21
+// +build go1.21
+
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+// slogRecorder is a slog handler keeping the records at level or above.
+type slogRecorder struct {
+	level   slog.Level
+	records []slog.Record
+}
+
+func (h *slogRecorder) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *slogRecorder) Handle(ctx context.Context, r slog.Record) error {
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *slogRecorder) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h *slogRecorder) WithGroup(name string) slog.Handler       { return h }
+
+func TestSlogHandler(t *testing.T) {
+	rec := &slogRecorder{level: slog.LevelInfo}
+	h := modbus.NewSlogHandler(slog.New(rec))
+	assertEquals(t, false, h.Enabled(modbus.LogLevelDebug))
+	assertEquals(t, true, h.Enabled(modbus.LogLevelInfo))
+	assertEquals(t, true, h.Enabled(modbus.LogLevelError))
+
+	failed := errors.New("broken pipe")
+	h.Handle(&modbus.LogRecord{
+		Level:         modbus.LogLevelError,
+		Message:       "request failed",
+		Direction:     modbus.DirectionSend,
+		SlaveID:       7,
+		FunctionCode:  modbus.FuncCodeReadHoldingRegisters,
+		TransactionID: 3,
+		Bytes:         12,
+		Frame:         []byte{0x01, 0xab},
+		Duration:      time.Second,
+		Attempt:       2,
+		Err:           failed,
+	})
+	h.Handle(&modbus.LogRecord{Level: modbus.LogLevelInfo, Message: "reconnecting", SlaveID: 1})
+
+	assertEquals(t, 2, len(rec.records))
+	r := rec.records[0]
+	assertEquals(t, slog.LevelError, r.Level)
+	assertEquals(t, "request failed", r.Message)
+	attrs := make(map[string]slog.Value)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	assertEquals(t, 9, len(attrs))
+	assertEquals(t, modbus.DirectionSend, attrs["direction"].String())
+	assertEquals(t, int64(7), attrs["slave_id"].Int64())
+	assertEquals(t, int64(modbus.FuncCodeReadHoldingRegisters), attrs["function_code"].Int64())
+	assertEquals(t, int64(3), attrs["transaction_id"].Int64())
+	assertEquals(t, int64(12), attrs["bytes"].Int64())
+	assertEquals(t, "01ab", attrs["frame"].String())
+	assertEquals(t, time.Second, attrs["duration"].Duration())
+	assertEquals(t, int64(2), attrs["attempt"].Int64())
+	assertEquals(t, failed, attrs["error"].Any())
+
+	// Unset fields are left out.
+	r = rec.records[1]
+	assertEquals(t, slog.LevelInfo, r.Level)
+	assertEquals(t, 2, r.NumAttrs())
+}
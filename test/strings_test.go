@@ -0,0 +1,102 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestStringFormat(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regs := cli.HoldingRegisters(100, 4)
+
+	// Odd length: the last byte is the high byte of the last register.
+	if err = regs.WriteString("ABC"); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x4142), s.holding[100])
+	assertEquals(t, uint16(0x4300), s.holding[101])
+	str, err := regs.ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "ABC", str)
+
+	swapped := modbus.StringFormat{SwapBytes: true, Padding: ' ', Fixed: true}
+	if err = regs.WriteStringFormat("ABC", swapped); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x4241), s.holding[100])
+	assertEquals(t, uint16(0x2043), s.holding[101])
+	assertEquals(t, uint16(0x2020), s.holding[103])
+	if str, err = regs.ReadStringFormat(swapped); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "ABC", str)
+
+	err = regs.WriteStringFormat("too long a string", modbus.StringFormat{})
+	if !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, actual %v", err)
+	}
+	truncated := modbus.StringFormat{Truncate: true, Charset: modbus.CharsetUTF8}
+	if err = regs.WriteStringFormat("1234567é", truncated); err != nil {
+		t.Fatal(err)
+	}
+	if str, err = regs.ReadStringFormat(truncated); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "1234567", str)
+
+	latin1 := modbus.StringFormat{Charset: modbus.CharsetLatin1}
+	if err = regs.WriteStringFormat("für", latin1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x66FC), s.holding[100])
+	if str, err = regs.ReadStringFormat(latin1); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "für", str)
+	if _, err = regs.ReadStringFormat(modbus.StringFormat{Charset: modbus.CharsetUTF8}); err == nil {
+		t.Fatal("expected invalid UTF-8")
+	}
+	if err = regs.WriteStringFormat("€", latin1); err == nil {
+		t.Fatal("expected error writing non Latin-1")
+	}
+	if err = regs.WriteStringFormat("für", modbus.StringFormat{Charset: modbus.CharsetASCII}); err == nil {
+		t.Fatal("expected error writing non ASCII")
+	}
+}
+
+func TestStructStringFormat(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Name string `modbus:"hr,10,len=3,swap,pad=space,charset=ascii"`
+	}
+	v.Name = "AB"
+	if err = cli.WriteStruct(&v); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x4241), s.holding[10])
+	assertEquals(t, uint16(0x2020), s.holding[12])
+	v.Name = ""
+	if err = cli.ReadStruct(&v); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "AB", v.Name)
+
+	var bad struct {
+		Count uint16 `modbus:"hr,10,swap"`
+	}
+	if err = cli.ReadStruct(&bad); err == nil {
+		t.Fatal("expected error for string option on number")
+	}
+}
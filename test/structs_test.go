@@ -0,0 +1,145 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+type meter struct {
+	Voltage float32  `modbus:"hr,3000,cdab,scale=0.1"`
+	Current int32    `modbus:"hr,3002"`
+	Serial  string   `modbus:"hr,3010,len=4,ro"`
+	Energy  float64  `modbus:"ir,100,type=uint32,scale=0.01"`
+	Alarm   bool     `modbus:"di,12"`
+	Relays  []bool   `modbus:"coil,0,len=3"`
+	Raw     []uint16 `modbus:"hr,4000,len=2"`
+	Ignored int
+}
+
+func TestStruct(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2301.5 as float32 0x450FD800, words swapped
+	s.holding[3000], s.holding[3001] = 0xD800, 0x450F
+	s.holding[3002], s.holding[3003] = 0xFFFF, 0xFFFE
+	s.holding[3010], s.holding[3011] = 0x4142, 0x4300
+	s.registers[100], s.registers[101] = 0x0001, 0x0000
+	s.inputs[12] = true
+	s.coils[1] = true
+	s.holding[4000] = 7
+
+	var m meter
+	if err = cli.ReadStruct(&m); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, float32(230.15), m.Voltage)
+	assertEquals(t, int32(-2), m.Current)
+	assertEquals(t, "ABC", m.Serial)
+	assertEquals(t, 655.36, m.Energy)
+	assertEquals(t, true, m.Alarm)
+	assertEquals(t, 3, len(m.Relays))
+	assertEquals(t, true, m.Relays[1])
+	assertEquals(t, uint16(7), m.Raw[0])
+	// Voltage and current are adjacent, serial and raw are not: three
+	// requests.
+	assertEquals(t, 3, s.requests[modbus.FuncCodeReadHoldingRegisters])
+
+	m.Voltage = 231
+	m.Current = 5
+	m.Serial = "XYZ"
+	m.Relays = []bool{true, false, true}
+	if err = cli.WriteStruct(&m); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, s.requests[modbus.FuncCodeWriteMultipleRegisters])
+	assertEquals(t, uint16(5), s.holding[3003])
+	assertEquals(t, uint16(0x4142), s.holding[3010])
+	assertEquals(t, true, s.coils[2])
+	if err = cli.ReadStruct(&m); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, float32(231), m.Voltage)
+
+	codec := modbus.NumberCodec(modbus.TypeFloat32, modbus.OrderCDAB)
+	v, err := cli.HoldingRegisters(3000, 2).ReadAs(codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, float32(2310), v)
+	if err = cli.HoldingRegisters(3000, 2).WriteAs(codec, 1.5); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, uint16(0x3FC0), s.holding[3001])
+	if _, err = cli.HoldingRegisters(3000, 1).ReadAs(codec); !errors.Is(err, modbus.ErrInvalidQuantity) {
+		t.Fatalf("expected invalid quantity, got %v", err)
+	}
+
+	// A field is left unchanged if the value read does not fit.
+	var small struct {
+		A uint16 `modbus:"hr,0"`
+		B int8   `modbus:"hr,1,type=int32"`
+	}
+	small.B = 1
+	s.holding[1], s.holding[2] = 0, 300
+	if err = cli.ReadStruct(&small); err == nil {
+		t.Fatal("expected overflow error")
+	}
+	assertEquals(t, int8(1), small.B)
+
+	// Nothing is written if a field does not encode.
+	var wide struct {
+		A uint16 `modbus:"hr,0"`
+		B uint32 `modbus:"hr,1,type=int16"`
+	}
+	wide.A, wide.B = 9, 40000
+	s.holding[0] = 0
+	writes := s.requests[modbus.FuncCodeWriteMultipleRegisters]
+	if err = cli.WriteStruct(&wide); err == nil {
+		t.Fatal("expected overflow error")
+	}
+	assertEquals(t, writes, s.requests[modbus.FuncCodeWriteMultipleRegisters])
+	assertEquals(t, uint16(0), s.holding[0])
+}
+
+func TestStructInvalid(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []interface{}{
+		meter{},
+		&struct {
+			A int `modbus:"hr,0"`
+		}{},
+		&struct {
+			A string `modbus:"hr,0"`
+		}{},
+		&struct {
+			A bool `modbus:"hr,0"`
+		}{},
+		&struct {
+			A uint16 `modbus:"xx,0"`
+		}{},
+		&struct {
+			A uint16 `modbus:"hr,0,abcd,bogus"`
+		}{},
+	}
+	for _, v := range tests {
+		if err = cli.ReadStruct(v); err == nil {
+			t.Errorf("expected error for %T", v)
+		}
+	}
+
+	s.exceptions[modbus.FuncCodeReadHoldingRegisters] = modbus.ExceptionCodeIllegalDataAddress
+	var m meter
+	var me *modbus.ModbusError
+	if err = cli.ReadStruct(&m); !errors.As(err, &me) {
+		t.Fatalf("expected exception, got %v", err)
+	}
+}
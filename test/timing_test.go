@@ -0,0 +1,99 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+// echoPort is a serial port whose slaves echo every request, as they do
+// for Write Single Register, and ignore broadcasts.
+type echoPort struct {
+	mu       sync.Mutex
+	response bytes.Buffer
+	writes   []time.Time
+}
+
+func (p *echoPort) Connect() error                             { return nil }
+func (p *echoPort) Close() error                               { return nil }
+func (p *echoPort) Flush() error                               { return nil }
+func (p *echoPort) SetReadTimeout(timeout time.Duration) error { return nil }
+
+func (p *echoPort) Write(adu []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writes = append(p.writes, time.Now())
+	if adu[0] != 0 {
+		p.response.Write(adu)
+	}
+	return len(adu), nil
+}
+
+func (p *echoPort) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.response.Read(b)
+}
+
+func TestSerialTiming(t *testing.T) {
+	const (
+		gap    = 20 * time.Millisecond
+		settle = 60 * time.Millisecond
+	)
+	port := &echoPort{}
+	cli, err := modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithUnitID(1),
+		modbus.WithSerialTiming(modbus.SerialTiming{Gap: gap, Turnaround: settle}),
+		modbus.WithSlaveTiming(2, modbus.SerialTiming{Gap: gap, Settle: settle}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two goroutines sharing the client still keep the gap.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cli.WriteSingleRegister(1, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// Broadcast, then turnaround
+	cli.SetSlaveID(0)
+	if err = cli.WriteSingleRegister(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	// A broadcast read fails without waiting for the turnaround.
+	start := time.Now()
+	if _, err = cli.ReadHoldingRegisters(1, 1); !errors.Is(err, modbus.ErrBroadcast) {
+		t.Fatalf("expected broadcast read to fail, actual %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= settle/2 {
+		t.Errorf("expected broadcast read to fail at once, actual %v", elapsed)
+	}
+	// Settle after a write to slave 2
+	cli.SetSlaveID(2)
+	if err = cli.WriteSingleRegister(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleRegister(1, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEquals(t, 5, len(port.writes))
+	expected := []time.Duration{gap, gap, settle, settle}
+	for i, d := range expected {
+		if actual := port.writes[i+1].Sub(port.writes[i]); actual < d {
+			t.Errorf("delay %v: expected at least %v, actual %v", i, d, actual)
+		}
+	}
+
+	if _, err = modbus.NewClientHandler(&modbus.RTUPackager{}, port, modbus.WithSerialTiming(modbus.SerialTiming{Gap: -1})); err == nil {
+		t.Fatal("expected error for negative gap")
+	}
+}
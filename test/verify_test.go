@@ -0,0 +1,59 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/xft/modbus"
+)
+
+func TestWriteVerify(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithWriteVerify(modbus.WriteVerify{
+		Float32:   []uint16{10},
+		Tolerance: 0.01,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteSingleCoil(3, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteMultipleCoils(0, []bool{true, false}); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, s.requests[modbus.FuncCodeReadCoils])
+
+	s.clamp = 1000
+	if err = cli.WriteSingleRegister(0, 500); err != nil {
+		t.Fatal(err)
+	}
+	err = cli.WriteSingleRegister(0, 1500)
+	var verr *modbus.WriteVerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected write verify error, actual %v", err)
+	}
+	assertEquals(t, byte(modbus.FuncCodeWriteSingleRegister), verr.FunctionCode)
+	err = cli.WriteMultipleRegisters(5, []uint16{1, 2000, 3, 4000})
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected write verify error, actual %v", err)
+	}
+	assertEquals(t, true, reflect.DeepEqual([]uint16{6, 8}, verr.Addresses))
+
+	// A float written as 0x3F80_FFFF reads back as 0x3F80_3F80, both
+	// about 1.0.
+	s.clamp = 0x3F80
+	one := math.Float32bits(1)
+	if err = cli.WriteMultipleRegisters(10, []uint16{uint16(one >> 16), 0xFFFF}); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.WriteMultipleRegisters(11, []uint16{0xFFFF}); !errors.As(err, &verr) {
+		t.Fatalf("expected write verify error outside the float, actual %v", err)
+	}
+
+	if _, err = modbus.NewClientHandler(&modbus.TCPPackager{}, s, modbus.WithWriteVerify(modbus.WriteVerify{Tolerance: -1})); err == nil {
+		t.Fatal("expected error for negative tolerance")
+	}
+}
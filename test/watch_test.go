@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xft/modbus"
+)
+
+func TestWatch(t *testing.T) {
+	s := newSlave()
+	cli, err := modbus.NewClientHandler(&modbus.TCPPackager{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := modbus.NewPoller(cli)
+	events := make(chan modbus.Event, 16)
+	err = p.WatchChan(modbus.Watch{
+		Point:    modbus.Point{Name: "level", Table: modbus.TableHoldingRegisters, Address: 10, Rate: time.Millisecond},
+		Absolute: 5,
+	}, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coilEvents []modbus.Event
+	err = p.Watch(modbus.Watch{
+		Point: modbus.Point{Name: "pump", Table: modbus.TableCoils, Address: 3, Rate: time.Millisecond},
+	}, func(e modbus.Event) {
+		coilEvents = append(coilEvents, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Watch(modbus.Watch{
+		Point: modbus.Point{Table: modbus.TableHoldingRegisters, Quantity: 1, Rate: time.Second},
+		Codec: modbus.NumberCodec(modbus.TypeFloat32, modbus.OrderABCD),
+	}, func(modbus.Event) {})
+	if err == nil {
+		t.Fatal("expected error for codec not matching the quantity")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx)
+	}()
+	next := func() modbus.Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return modbus.Event{}
+	}
+
+	s.mu.Lock()
+	s.holding[10] = 100
+	s.mu.Unlock()
+	e := next()
+	assertEquals(t, modbus.QualityGood, e.Quality)
+	assertEquals(t, modbus.QualityUnknown, e.Previous)
+
+	// Within the deadband of the first value read, then beyond it.
+	s.mu.Lock()
+	s.holding[10] = e.Registers[0] + 5
+	s.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	s.mu.Lock()
+	s.holding[10] = e.Registers[0] + 6
+	s.mu.Unlock()
+	assertEquals(t, e.Registers[0]+6, next().Registers[0])
+
+	s.mu.Lock()
+	s.exceptions[modbus.FuncCodeReadHoldingRegisters] = modbus.ExceptionCodeServerDeviceBusy
+	s.mu.Unlock()
+	e = next()
+	assertEquals(t, modbus.QualityCommFailure, e.Quality)
+	if e.Err == nil {
+		t.Fatal("expected error of comm failure")
+	}
+	s.mu.Lock()
+	delete(s.exceptions, modbus.FuncCodeReadHoldingRegisters)
+	s.mu.Unlock()
+	e = next()
+	assertEquals(t, modbus.QualityGood, e.Quality)
+	assertEquals(t, modbus.QualityCommFailure, e.Previous)
+
+	s.mu.Lock()
+	s.coils[3] = true
+	s.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	assertEquals(t, 2, len(coilEvents))
+	assertEquals(t, true, coilEvents[1].Bits[0])
+}
@@ -0,0 +1,111 @@
+package modbus
+
+import (
+	"fmt"
+	"time"
+)
+
+// SerialTiming are the quiet times a serial bus needs between frames,
+// enforced by the client across goroutines, see WithSerialTiming.
+type SerialTiming struct {
+	// Gap is the minimum time between the end of a response and the next
+	// request.
+	Gap time.Duration
+	// Turnaround is the time slaves need to process a broadcast, sent to
+	// slave 0 and not answered, before the next request.
+	Turnaround time.Duration
+	// Settle is the time a slave needs to apply a write before the next
+	// request.
+	Settle time.Duration
+}
+
+func (t SerialTiming) valid() error {
+	if t.Gap < 0 || t.Turnaround < 0 || t.Settle < 0 {
+		return fmt.Errorf("modbus: serial delays '%+v' must not be negative", t)
+	}
+	return nil
+}
+
+// timingOf returns the timing of requests to slaveID. The caller must hold
+// c.mu.
+func (c *ClientHandler) timingOf(slaveID byte) SerialTiming {
+	if t, ok := c.slaveTiming[slaveID]; ok {
+		return t
+	}
+	return c.timing
+}
+
+// quietUntil returns the end of the quiet time of the line, shared by the
+// clients of a bus. The caller must hold c.mu and its turn on the bus.
+func (c *ClientHandler) quietUntil() *time.Time {
+	if c.bus != nil {
+		return &c.bus.quietUntil
+	}
+	return &c.quietTime
+}
+
+// wait sleeps until the bus is quiet. The caller must hold c.mu.
+func (c *ClientHandler) wait() {
+	if d := time.Until(*c.quietUntil()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// quiet sets the time the bus is quiet after a request to the slave of c
+// with functionCode completed. The caller must hold c.mu.
+func (c *ClientHandler) quiet(functionCode byte, broadcast bool) {
+	t := c.timingOf(c.SlaveID)
+	d := t.Gap
+	if broadcast && t.Turnaround > d {
+		d = t.Turnaround
+	}
+	if writeFunction(functionCode) && t.Settle > d {
+		d = t.Settle
+	}
+	*c.quietUntil() = time.Now().Add(d)
+}
+
+// writeFunction reports whether functionCode changes coils or registers.
+func writeFunction(functionCode byte) bool {
+	switch functionCode {
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister, FuncCodeWriteMultipleCoils,
+		FuncCodeWriteMultipleRegisters, FuncCodeMaskWriteRegister, FuncCodeReadWriteMultipleRegisters:
+		return true
+	}
+	return false
+}
+
+// isBroadcast reports whether requests go to all slaves of a serial bus.
+// The caller must hold c.mu.
+func (c *ClientHandler) isBroadcast() bool {
+	_, tcp := c.Packager.(*TCPPackager)
+	return !tcp && c.SlaveID == 0
+}
+
+// broadcastable reports whether requests with functionCode may be
+// broadcast: writes whose response only echoes the request.
+func broadcastable(functionCode byte) bool {
+	switch functionCode {
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister, FuncCodeWriteMultipleCoils,
+		FuncCodeWriteMultipleRegisters, FuncCodeMaskWriteRegister:
+		return true
+	}
+	return false
+}
+
+// broadcast sends a write request to all slaves, which do not answer, and
+// returns the response a slave would give. The caller must hold c.mu and
+// have checked the function code with broadcastable.
+func (c *ClientHandler) broadcast(request *ProtocolDataUnit, aduRequest []byte) (response *ProtocolDataUnit, err error) {
+	n := 4
+	if request.FunctionCode == FuncCodeMaskWriteRegister {
+		n = 6
+	}
+	if err = c.Transporter.Connect(); err != nil {
+		return
+	}
+	if _, err = c.Transporter.Write(aduRequest); err != nil {
+		return
+	}
+	return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data[:n]}, nil
+}
@@ -0,0 +1,114 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// WriteVerify configures the read-back of writes, see WithWriteVerify.
+type WriteVerify struct {
+	// Float32 lists the first holding register of each float32 value,
+	// laid out in Order. Written floats compare within Tolerance, all other
+	// registers and coils must read back exactly.
+	Float32   []uint16
+	Order     WordOrder
+	Tolerance float64
+}
+
+// WriteVerifyError reports a write that the slave acknowledged but that
+// read back different, e.g. because the device clamped the value.
+type WriteVerifyError struct {
+	FunctionCode byte
+	// Addresses of the coils or registers that differ, the first register
+	// of floats.
+	Addresses []uint16
+}
+
+func (e *WriteVerifyError) Error() string {
+	return fmt.Sprintf("modbus: function %v read back different at addresses %v", e.FunctionCode, e.Addresses)
+}
+
+// writeVerify is the read-back configuration of a client.
+type writeVerify struct {
+	WriteVerify
+	floats map[uint16]bool
+}
+
+// verifyWrite reads back the coils or registers written by r, if
+// configured. Broadcasts cannot be read back. The caller must hold c.mu.
+func (c *ClientHandler) verifyWrite(r *request) error {
+	if c.verify == nil || c.isBroadcast() {
+		return nil
+	}
+	if r.coils != nil {
+		return c.verifyCoils(r.pdu.FunctionCode, r.address, r.coils)
+	}
+	if r.registers != nil {
+		return c.verifyRegisters(r.pdu.FunctionCode, r.address, r.registers)
+	}
+	return nil
+}
+
+// verifyCoils reads back coils written by functionCode at address. The
+// caller must hold c.mu.
+func (c *ClientHandler) verifyCoils(functionCode byte, address uint16, written []bool) (err error) {
+	res := c.send(readBitsRequest(FuncCodeReadCoils, address, uint16(len(written))))
+	if err = res.err; err != nil {
+		return
+	}
+	var differ []uint16
+	for i, w := range written {
+		if res.bits[i] != w {
+			differ = append(differ, address+uint16(i))
+		}
+	}
+	if differ != nil {
+		err = &WriteVerifyError{FunctionCode: functionCode, Addresses: differ}
+	}
+	return
+}
+
+// verifyRegisters reads back holding registers written by functionCode at
+// address. The caller must hold c.mu.
+func (c *ClientHandler) verifyRegisters(functionCode byte, address uint16, written []uint16) (err error) {
+	v := c.verify
+	res := c.send(readRegistersRequest(c.enron, FuncCodeReadHoldingRegisters, address, uint16(len(written))))
+	if err = res.err; err != nil {
+		return
+	}
+	values := res.registers
+	if len(values) != len(written) {
+		e := requestErrorf(ErrLengthMismatch, FuncCodeReadHoldingRegisters, "modbus: read-back of '%v' registers returned '%v'", len(written), len(values))
+		c.annotate(e, FuncCodeReadHoldingRegisters)
+		return e
+	}
+	float := NumberCodec(TypeFloat32, v.Order)
+	var differ []uint16
+	for i := 0; i < len(written); i++ {
+		at := address + uint16(i)
+		if v.floats[at] && i+1 < len(written) {
+			w, _ := float.Decode(written[i : i+2])
+			r, _ := float.Decode(values[i : i+2])
+			if !v.within(float64(w.(float32)), float64(r.(float32))) {
+				differ = append(differ, at)
+			}
+			i++
+			continue
+		}
+		if values[i] != written[i] {
+			differ = append(differ, at)
+		}
+	}
+	if differ != nil {
+		err = &WriteVerifyError{FunctionCode: functionCode, Addresses: differ}
+	}
+	return
+}
+
+// within reports whether a float read back r matches w written.
+func (v *writeVerify) within(w, r float64) bool {
+	if math.IsNaN(w) || math.IsNaN(r) {
+		return math.IsNaN(w) && math.IsNaN(r)
+	}
+	return math.Abs(w-r) <= v.Tolerance
+}
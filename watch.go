@@ -0,0 +1,207 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Quality tells whether a watched value is current.
+type Quality byte
+
+const (
+	// QualityUnknown is the quality before the first poll.
+	QualityUnknown Quality = iota
+	// QualityGood means the value was read in the last poll.
+	QualityGood
+	// QualityCommFailure means the last poll failed; the value is stale.
+	QualityCommFailure
+	// QualityBadValue means the registers could not be decoded.
+	QualityBadValue
+)
+
+func (q Quality) String() string {
+	switch q {
+	case QualityUnknown:
+		return "unknown"
+	case QualityGood:
+		return "good"
+	case QualityCommFailure:
+		return "comm failure"
+	case QualityBadValue:
+		return "bad value"
+	}
+	return fmt.Sprintf("Quality(%d)", byte(q))
+}
+
+// Watch subscribes to the changes of a point.
+type Watch struct {
+	Point Point
+	// Codec, if set, decodes the registers of the point into the value
+	// compared against the deadband. Otherwise every register is compared
+	// on its own as an unsigned number.
+	Codec Codec
+	// Absolute is the smallest change of a register value reported.
+	Absolute float64
+	// Percent is the smallest change reported, in percent of the value
+	// last reported. A change must exceed both deadbands; with neither set,
+	// every change is reported.
+	Percent float64
+}
+
+// Event reports a change of a watched point.
+type Event struct {
+	Point Point
+	Time  time.Time
+	// Quality of the value, and its quality in the previous event.
+	Quality  Quality
+	Previous Quality
+	// Bits or Registers hold the values when the quality is good.
+	Bits      []bool
+	Registers []uint16
+	// Value is decoded from Registers by the codec of the watch, if any.
+	Value interface{}
+	// Err is the cause of a comm failure or bad value.
+	Err error
+}
+
+// Watch polls a point and calls fn, from the goroutine running the
+// poller, when its value changes beyond the deadband or its quality
+// changes. The first successful poll is always reported. Bits are reported
+// on every flip.
+func (p *Poller) Watch(w Watch, fn func(Event)) error {
+	if fn == nil {
+		return fmt.Errorf("modbus: watch of point '%v' needs a handler", w.Point.Name)
+	}
+	return p.add(w.Point, &watcher{Watch: w, fn: fn})
+}
+
+// WatchChan is Watch delivering events on ch. Sends block polling, so ch
+// should be buffered and drained promptly.
+func (p *Poller) WatchChan(w Watch, ch chan<- Event) error {
+	if ch == nil {
+		return fmt.Errorf("modbus: watch of point '%v' needs a channel", w.Point.Name)
+	}
+	return p.Watch(w, func(e Event) {
+		ch <- e
+	})
+}
+
+// watcher keeps the last reported state of a watched point.
+type watcher struct {
+	Watch
+	fn func(Event)
+
+	quality   Quality
+	bits      []bool
+	registers []uint16
+	value     float64
+}
+
+// init validates the watch of pt, the point as registered.
+func (w *watcher) init(pt Point) error {
+	w.Point = pt
+	if w.Absolute < 0 || w.Percent < 0 {
+		return fmt.Errorf("modbus: deadband of point '%v' must not be negative", pt.Name)
+	}
+	if w.Codec != nil {
+		if pt.Table.IsBits() {
+			return fmt.Errorf("modbus: codec does not apply to %v of point '%v'", pt.Table, pt.Name)
+		}
+		if w.Codec.Words() != int(pt.Quantity) {
+			return fmt.Errorf("modbus: codec of %v registers does not match quantity '%v' of point '%v'", w.Codec.Words(), pt.Quantity, pt.Name)
+		}
+	}
+	return nil
+}
+
+// update compares a sample with the last reported state and emits an event
+// if it changed.
+func (w *watcher) update(s Sample) {
+	e := Event{Point: w.Point, Time: s.Time, Previous: w.quality, Err: s.Err}
+	if s.Err != nil {
+		if w.quality == QualityCommFailure {
+			return
+		}
+		e.Quality = QualityCommFailure
+		w.emit(e)
+		return
+	}
+
+	e.Bits, e.Registers = s.Bits, s.Registers
+	var value float64
+	var numeric bool
+	if w.Codec != nil {
+		var err error
+		if e.Value, err = w.Codec.Decode(s.Registers); err == nil {
+			var n number
+			if n, numeric = toNumber(e.Value); numeric {
+				value = n.float()
+			}
+		} else {
+			if w.quality == QualityBadValue {
+				return
+			}
+			e.Quality, e.Err = QualityBadValue, err
+			w.emit(e)
+			return
+		}
+	}
+	e.Quality = QualityGood
+	if w.quality == QualityGood && !w.changed(s, value, numeric) {
+		return
+	}
+	w.bits = s.Bits
+	w.registers = s.Registers
+	w.value = value
+	w.emit(e)
+}
+
+func (w *watcher) emit(e Event) {
+	w.quality = e.Quality
+	w.fn(e)
+}
+
+// changed reports whether a good sample differs from the last one reported
+// by more than the deadband. Decoded values that are not numbers, such as
+// strings, change with any register.
+func (w *watcher) changed(s Sample, value float64, numeric bool) bool {
+	for i, b := range s.Bits {
+		if b != w.bits[i] {
+			return true
+		}
+	}
+	if w.Codec != nil && numeric {
+		return w.beyond(w.value, value)
+	}
+	return w.registersChanged(s.Registers, w.Codec == nil)
+}
+
+// registersChanged compares registers one by one, against the deadband
+// if deadband is set.
+func (w *watcher) registersChanged(registers []uint16, deadband bool) bool {
+	for i, r := range registers {
+		if deadband && w.beyond(float64(w.registers[i]), float64(r)) {
+			return true
+		}
+		if !deadband && r != w.registers[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// beyond reports whether the change from last to v exceeds the deadbands.
+func (w *watcher) beyond(last, v float64) bool {
+	delta := math.Abs(v - last)
+	if delta == 0 || (math.IsNaN(last) && math.IsNaN(v)) {
+		return false
+	}
+	if w.Absolute > 0 && delta <= w.Absolute {
+		return false
+	}
+	if w.Percent > 0 && delta <= w.Percent/100*math.Abs(last) {
+		return false
+	}
+	return true
+}